@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/ed25519"
 	"errors"
 	"flag"
 	"fmt"
@@ -14,9 +16,9 @@ import (
 	"strings"
 	"text/tabwriter"
 	"text/template"
+	"time"
 
 	"github.com/urfave/cli"
-	"gopkg.in/yaml.v2"
 )
 
 // Config handles the overall configuration in an ahoy.yml file
@@ -27,6 +29,17 @@ type Config struct {
 	Commands   map[string]Command
 	Entrypoint []string
 	Env        StringArray
+	// TrustedKeys are the ed25519 public keys imported files must be signed with.
+	TrustedKeys StringArray `yaml:"trusted_keys"`
+	// Inherit also loads the .ahoy.yml files from parent directories.
+	Inherit bool
+	// Root stops parent directories being searched for inherited files.
+	Root bool
+	// RequiresAhoy is a version constraint, such as ">=2.4.0", the running
+	// version of ahoy must satisfy.
+	RequiresAhoy string `yaml:"requires_ahoy"`
+	// Requires lists the tools every command in the file needs.
+	Requires []Requirement
 }
 
 // Command is an ahoy command detailed in ahoy.yml files. Multiple
@@ -40,15 +53,55 @@ type Command struct {
 	Optional    bool
 	Imports     []string
 	Aliases     []string
+	Requires    []Requirement
+	// Cleanup runs after the command fails or is interrupted, with the
+	// command's exit code in AHOY_EXIT_CODE.
+	Cleanup string
+	// Timeout is how long the command can run for, such as "10m".
+	Timeout string
+	// Retry runs the command again if it fails.
+	Retry *Retry
+	// Sources are globs of the files the command reads. When they haven't
+	// changed since its last successful run, the command is skipped.
+	Sources []string
+	// Generates are globs of the files the command creates. The command runs
+	// again if any of them are missing, even when its sources haven't changed.
+	Generates []string
+	// Watch are globs of the files 'ahoy watch' runs the command again for,
+	// defaulting to its sources.
+	Watch []string
+	// Cache replays the command's output for a while, instead of running it.
+	Cache *Cache
+	// Lock stops the command running at the same time as itself when true,
+	// or as the other commands with the same lock when it's a name.
+	Lock string
+	// Confirm asks before running the command.
+	Confirm *Confirm
+	// Inputs are values the command asks for when they aren't given as
+	// arguments or in the environment.
+	Inputs []Input
+	// Complete is a snippet whose output lines are offered when completing
+	// the command's arguments in a shell.
+	Complete string
+	// defined is where the command was defined.
+	defined definition
+	// overlays are where the command was merged over, such as in a local
+	// override file.
+	overlays []definition
 }
 
 var (
-	app            *cli.App
-	sourcefile     string
+	app        *cli.App
+	sourcefile string
+	// sourcefiles are all the files passed using -f, merged in order.
+	sourcefiles    []string
 	verbose        bool
 	bashCompletion bool
 )
 
+// stdinConfigFile is passed to -f to read the config from stdin.
+const stdinConfigFile = "-"
+
 // The build version can be set using the go linker flag `-ldflags "-X main.version=$VERSION"`
 // Complete command: `go build -ldflags "-X main.version=$VERSION"`
 var version string
@@ -57,8 +110,25 @@ var version string
 var AhoyConf struct {
 	srcDir  string
 	srcFile string
+	// runDir overrides the directory commands run in, when it differs from
+	// the directory files are loaded relative to.
+	runDir string
+	// updateImports accepts changed content for unpinned remote imports.
+	updateImports bool
+	// cachedImportsOnly loads remote imports from the cache without
+	// downloading them, so shell completion doesn't wait on the network.
+	cachedImportsOnly bool
+	// trustedKeys must have signed every imported file, if any are set.
+	trustedKeys []ed25519.PublicKey
+	// configFiles are the local ahoy files loaded, which must be trusted.
+	configFiles []string
+	// configLevels are the project and user ahoy files loaded, which
+	// 'ahoy doctor' checks along with their imports.
+	configLevels []configLevel
 }
 
+// logger prints a message to stderr. Errors are returned up to main, which
+// logs them as "fatal" before exiting with the error's exit code.
 func logger(errType string, text string) {
 	errText := ""
 	// Disable the flags which add date and time for instance.
@@ -67,10 +137,6 @@ func logger(errType string, text string) {
 		errText = "[" + errType + "] " + text + "\n"
 		log.Println(errText)
 	}
-
-	if errType == "fatal" {
-		os.Exit(1)
-	}
 }
 
 func fileExists(filename string) bool {
@@ -86,8 +152,12 @@ func getConfigPath(sourcefile string) (string, error) {
 	config := ""
 
 	// If a specific source file was set, then try to load it directly.
+	if sourcefile == stdinConfigFile {
+		return sourcefile, nil
+	}
 	if sourcefile != "" {
 		if _, err := os.Stat(sourcefile); err == nil {
+			logLocalOverlay(sourcefile)
 			return sourcefile, err
 		}
 		err = errors.New("An ahoy config file was specified using -f to be at " + sourcefile + " but couldn't be found. Check your path.")
@@ -102,10 +172,13 @@ func getConfigPath(sourcefile string) (string, error) {
 	// Keep track of the previous directory to detect when we've reached the root
 	prevDir := ""
 	for dir != prevDir && err == nil {
-		ymlpath := filepath.Join(dir, ".ahoy.yml")
-		// log.Println(ymlpath)
-		if _, err := os.Stat(ymlpath); err == nil {
+		ymlpath, err := findConfigFile(dir)
+		if err != nil {
+			return config, err
+		}
+		if ymlpath != "" {
 			logger("debug", "Found .ahoy.yml at "+ymlpath)
+			logLocalOverlay(ymlpath)
 			return ymlpath, err
 		}
 		// Chop off the last part of the path.
@@ -116,58 +189,131 @@ func getConfigPath(sourcefile string) (string, error) {
 	return "", err
 }
 
+// logLocalOverlay reports in verbose mode when a config file has a local overlay.
+func logLocalOverlay(file string) {
+	if overlayFile := localOverlayPath(file); verbose && fileExists(overlayFile) {
+		log.Println("===> Ahoy found local overlay", overlayFile, "for", file)
+	}
+}
+
 func getConfig(file string) (Config, error) {
 	config := Config{}
-	yamlFile, err := os.ReadFile(file)
+	var yamlFile []byte
+	var err error
+	if file == stdinConfigFile {
+		yamlFile, err = io.ReadAll(os.Stdin)
+	} else {
+		yamlFile, err = os.ReadFile(file)
+	}
 	if err != nil {
 		err = errors.New("an ahoy config file couldn't be found in your path. You can create an example one by using 'ahoy init'")
 		return config, err
 	}
 
-	// Extract the yaml file into the config variable.
-	err = yaml.Unmarshal(yamlFile, &config)
+	// Extract the yaml file into the config variable. All ahoy files (and
+	// imports) must specify the ahoy API version they are written for.
+	err = unmarshalConfig(file, yamlFile, &config)
 	if err != nil {
 		return config, err
 	}
 
-	// All ahoy files (and imports) must specify the ahoy version.
-	// This is so we can support backwards compatibility in the future.
-	if config.AhoyAPI != "v2" {
-		err = errors.New("Ahoy only supports API version 'v2', but '" + config.AhoyAPI + "' given in " + sourcefile)
-		return config, err
-	}
-
 	if config.Entrypoint == nil {
 		config.Entrypoint = []string{"bash", "-c", "{{cmd}}", "{{name}}"}
 	}
+	lines := commandLines(file, yamlFile)
+	for name, cmd := range config.Commands {
+		cmd.defined = definition{file: file, line: lines[name]}
+		config.Commands[name] = cmd
+	}
 
 	return config, err
 }
 
-func getSubCommands(includes []string) []cli.Command {
+// resolveImport returns the local path of an import, downloading remote
+// imports into the cache. The path is empty if a local import doesn't exist.
+func resolveImport(include string) (string, error) {
+	// Remote imports are downloaded into the cache and loaded from there.
+	if isRemoteImport(include) {
+		return fetchRemoteImport(include)
+	}
+	if strings.HasPrefix(include, "http://") {
+		return "", &importIntegrityError{"Refusing import " + include + ": remote imports must use https."}
+	}
+	if !strings.HasPrefix(include, "/") && !strings.HasPrefix(include, "~") {
+		// If the include path is not absolute or a home directory path,
+		// prepend the source directory to make it relative to the config file.
+		include = filepath.Join(AhoyConf.srcDir, include)
+	}
+	if _, err := os.Stat(include); err != nil {
+		return "", nil
+	}
+	return include, nil
+}
+
+func getSubCommands(includes []string) ([]cli.Command, error) {
+	subCommands, _, err := loadSubCommands(includes, nil)
+	return subCommands, err
+}
+
+// loadSubCommands loads the commands imported from files by the command at
+// parents, along with where they came from. Commands in later files replace
+// those with the same name in earlier ones.
+func loadSubCommands(includes []string, parents []string) ([]cli.Command, map[string]*commandSource, error) {
 	subCommands := []cli.Command{}
+	sources := map[string]*commandSource{}
 	if len(includes) == 0 {
-		return subCommands
+		return subCommands, sources, nil
 	}
 	commands := map[string]cli.Command{}
 	for _, include := range includes {
 		if len(include) == 0 {
 			continue
 		}
-		// If the include path is not absolute or a home directory path,
-		// prepend the source directory to make it relative to the config file.
-		if !strings.HasPrefix(include, "/") && !strings.HasPrefix(include, "~") {
-			include = filepath.Join(AhoyConf.srcDir, include)
+		source := include
+		path, err := resolveImport(include)
+		if err != nil {
+			var integrityErr *importIntegrityError
+			if errors.As(err, &integrityErr) {
+				return nil, nil, newAhoyError(exitUntrusted, err)
+			}
+			logger("warn", err.Error())
+			continue
 		}
-		if _, err := os.Stat(include); err != nil {
+		if path == "" {
 			// Skipping files that cannot be loaded allows us to separate
 			// subcommands into public and private.
 			continue
 		}
-		config, _ := getConfig(include)
-		includeCommands := getCommands(config)
+		include = path
+		if !isRemoteImport(source) {
+			trackConfigFile(include)
+		}
+		// Verify signatures before any command from the import is registered.
+		if err := verifyImportSignature(include, source); err != nil {
+			return nil, nil, newAhoyError(exitUntrusted, err)
+		}
+		config, err := getConfig(include)
+		var versionErr *ahoyVersionError
+		if errors.As(err, &versionErr) {
+			return nil, nil, configError(err)
+		}
+		if isRemoteImport(source) {
+			// Remote imports are shown by their URL, rather than where they're cached.
+			for name, cmd := range config.Commands {
+				cmd.defined.file = source
+				config.Commands[name] = cmd
+			}
+		}
+		includeCommands, includeSources, err := loadCommands(config, parents)
+		if err != nil {
+			return nil, nil, err
+		}
 		for _, command := range includeCommands {
 			commands[command.Name] = command
+			if previous, found := sources[command.Name]; found {
+				includeSources[command.Name].override(previous)
+			}
+			sources[command.Name] = includeSources[command.Name]
 		}
 	}
 
@@ -179,7 +325,7 @@ func getSubCommands(includes []string) []cli.Command {
 	for _, name := range names {
 		subCommands = append(subCommands, commands[name])
 	}
-	return subCommands
+	return subCommands, sources, nil
 }
 
 // Given a filepath, return a string array of environment variables.
@@ -209,14 +355,44 @@ func getEnvironmentVars(envFile string) []string {
 	return envVars
 }
 
-func getCommands(config Config) []cli.Command {
+// entrypointCommand replaces the placeholders in an entrypoint with the script
+// to run and the command's name, and adds the command's arguments.
+func entrypointCommand(entrypoint []string, script string, name string, args []string) []string {
+	items := []string{}
+	for _, item := range entrypoint {
+		switch item {
+		case "{{cmd}}":
+			item = script
+		case "{{name}}":
+			item = name
+		}
+		items = append(items, item)
+	}
+	return append(items, args...)
+}
+
+func getCommands(config Config) ([]cli.Command, error) {
+	commands, _, err := loadCommands(config, nil)
+	return commands, err
+}
+
+// loadCommands creates the commands in a config, along with where they came
+// from. Parents are the names of the commands that imported the config.
+func loadCommands(config Config, parents []string) ([]cli.Command, map[string]*commandSource, error) {
 	exportCmds := []cli.Command{}
+	sources := map[string]*commandSource{}
 	envVars := []string{}
+	// Commands run relative to the directory of the file being loaded.
+	srcDir := AhoyConf.srcDir
+	runDir := srcDir
+	if AhoyConf.runDir != "" {
+		runDir = AhoyConf.runDir
+	}
 
 	// Get environment variables from the 'global' environment variable file, if it is defined.
 	if len(config.Env) > 0 {
 		for _, envPath := range config.Env {
-			globalEnvFile := filepath.Join(AhoyConf.srcDir, envPath)
+			globalEnvFile := filepath.Join(srcDir, envPath)
 			vars := getEnvironmentVars(globalEnvFile)
 			if vars != nil {
 				envVars = append(envVars, vars...)
@@ -232,20 +408,24 @@ func getCommands(config Config) []cli.Command {
 
 	for _, name := range keys {
 		cmd := config.Commands[name]
+		path := append(append([]string{}, parents...), name)
+		// Subcommands with the same name in different imports keep their
+		// fingerprints, locks and cached output apart using their full name.
+		fullName := strings.Join(path, " ")
 
 		// Check that a command has 'cmd' OR 'imports' set.
 		if cmd.Cmd == "" && cmd.Imports == nil {
-			logger("fatal", "Command ["+name+"] has neither 'cmd' or 'imports' set. Check your yaml file.")
+			return nil, nil, commandError(cmd, "Command ["+name+"] has neither 'cmd' or 'imports' set. Check your yaml file.")
 		}
 
 		// Check if a command has 'cmd' AND 'imports' set.
 		if cmd.Cmd != "" && cmd.Imports != nil {
-			logger("fatal", "Command ["+name+"] has both 'cmd' and 'imports' set, but only one is allowed. Check your yaml file.")
+			return nil, nil, commandError(cmd, "Command ["+name+"] has both 'cmd' and 'imports' set, but only one is allowed. Check your yaml file.")
 		}
 
 		// Check that a command with 'imports' set has a least one entry.
 		if cmd.Imports != nil && len(cmd.Imports) == 0 {
-			logger("fatal", "Command ["+name+"] has 'imports' set, but it is empty. Check your yaml file.")
+			return nil, nil, commandError(cmd, "Command ["+name+"] has 'imports' set, but it is empty. Check your yaml file.")
 		}
 
 		newCmd := cli.Command{
@@ -263,41 +443,56 @@ func getCommands(config Config) []cli.Command {
 			newCmd.Description = cmd.Description
 		}
 
+		var timeout time.Duration
+		if cmd.Timeout != "" {
+			var err error
+			timeout, err = time.ParseDuration(cmd.Timeout)
+			if err != nil || timeout < 0 {
+				return nil, nil, commandError(cmd, "Command ["+name+"] has an invalid timeout '"+cmd.Timeout+"'. Use a duration such as '30s' or '10m'.")
+			}
+		}
+
+		retry, err := newRetryPolicy(cmd.Retry)
+		if err != nil {
+			return nil, nil, commandError(cmd, "Command ["+name+"] has an invalid retry: "+err.Error()+".")
+		}
+
+		cacheTTL, err := parseCacheTTL(cmd.Cache)
+		if err != nil {
+			return nil, nil, commandError(cmd, "Command ["+name+"] has an invalid cache: "+err.Error()+".")
+		}
+
+		if err := validateInputs(cmd.Inputs); err != nil {
+			return nil, nil, commandError(cmd, "Command ["+name+"] has an invalid input: "+err.Error()+".")
+		}
+
 		if cmd.Cmd != "" {
-			newCmd.Action = func(c *cli.Context) {
+			requires := append(append([]Requirement{}, config.Requires...), cmd.Requires...)
+			newCmd.Action = func(c *cli.Context) error {
 				// For some unclear reason, if we don't add an item at the end here,
 				// the first argument is skipped... actually it's not!
 				// 'bash -c' says that arguments will be passed starting with $0, which also means that
 				// $@ skips the first item. See http://stackoverflow.com/questions/41043163/xargs-sh-c-skipping-the-first-argument
 				var cmdItems []string
 				var cmdArgs []string
-				var cmdEntrypoint []string
 
-				// c.Args()  is not a slice apparently.
-				for _, arg := range c.Args() {
+				// Inputs given as --name=value aren't passed on to the command.
+				inputValues, inputSources, args := inputArguments(cmd.Inputs, c.Args())
+				for _, arg := range args {
 					if arg != "--" {
 						cmdArgs = append(cmdArgs, arg)
 					}
 				}
 				// fmt.Printf("%s : %+v\n", "Args", cmdArgs)
 
-				// Replace the entry point placeholders.
-				cmdEntrypoint = config.Entrypoint[:]
-				for i := range cmdEntrypoint {
-					if cmdEntrypoint[i] == "{{cmd}}" {
-						cmdEntrypoint[i] = cmd.Cmd
-					} else if cmdEntrypoint[i] == "{{name}}" {
-						cmdEntrypoint[i] = c.Command.Name
-					}
-				}
-				cmdItems = append(cmdEntrypoint, cmdArgs...)
+				cmdItems = entrypointCommand(config.Entrypoint, cmd.Cmd, c.Command.Name, cmdArgs)
 
 				// If defined, included specified command-level environment variables.
 				// Note that this will intentionally override any conflicting variables
 				// defined in the 'global' env file.
 				if len(cmd.Env) > 0 {
 					for _, envPath := range cmd.Env {
-						cmdEnvFile := filepath.Join(AhoyConf.srcDir, envPath)
+						cmdEnvFile := filepath.Join(srcDir, envPath)
 						vars := getEnvironmentVars(cmdEnvFile)
 						if vars != nil {
 							envVars = append(envVars, vars...)
@@ -305,39 +500,220 @@ func getCommands(config Config) []cli.Command {
 					}
 				}
 
+				if completing != nil {
+					completing.run(entrypointCommand(config.Entrypoint, cmd.Complete, c.Command.Name, cmdArgs), runDir, envVars)
+					return nil
+				}
+
+				runTimeout := timeout
+				if commandTimeout > 0 {
+					runTimeout = commandTimeout
+				}
+				if dryRun {
+					printPlan(os.Stdout, commandPlan{
+						name:         c.Command.Name,
+						fullName:     fullName,
+						cmd:          cmd,
+						argv:         cmdItems,
+						dir:          runDir,
+						srcDir:       srcDir,
+						envFiles:     append(append([]string{}, config.Env...), cmd.Env...),
+						inputValues:  inputValues,
+						inputSources: inputSources,
+						interactive:  isTerminal(os.Stdin),
+						requires:     requires,
+						timeout:      runTimeout,
+						retry:        retry,
+						cacheTTL:     cacheTTL,
+						args:         cmdArgs,
+					})
+					return nil
+				}
+
+				if err := ensureTrusted(c.Command.Name); err != nil {
+					return newAhoyError(exitUntrusted, err)
+				}
+				// Requirements can run probes from the file, so check them once it's trusted.
+				if err := checkRequirements(c.Command.Name, requires); err != nil {
+					return newAhoyError(exitMissingRequirement, err)
+				}
+
 				if verbose {
-					log.Println("===> Ahoy", name, "from", sourcefile, ":", cmdItems)
+					log.Println("===> Ahoy", name, "from", cmd.defined, ":", cmdItems)
+				}
+
+				if len(cmd.Inputs) > 0 {
+					vars, err := resolveInputs(c.Command.Name, cmd.Inputs, inputValues, envVars, os.Stdin, os.Stderr, isTerminal(os.Stdin))
+					if err != nil {
+						return err
+					}
+					envVars = append(envVars, vars...)
+				}
+
+				// The cached result is read once, so the command can't run
+				// without being confirmed if it expires before it's replayed.
+				cacheFile, cached, replaying := "", cachedResult{}, false
+				if cmd.Cache != nil {
+					var err error
+					if cacheFile, err = resultCachePath(fullName, cmd, cmdArgs, runDir, envVars); err != nil {
+						return errors.New("Could not find the cache for '" + c.Command.Name + "': " + err.Error())
+					}
+					if !force {
+						cached, replaying = readCachedResult(cacheFile, cacheTTL)
+					}
+				}
+
+				// Replaying cached output doesn't run anything, so the command is
+				// only confirmed before it first runs.
+				confirmed := !cmd.Confirm.enabled()
+				confirm := func() (bool, error) {
+					if confirmed {
+						return true, nil
+					}
+					message := confirmMessage(c.Command.Name, cmd.Confirm, cmdArgs, envVars)
+					var err error
+					if confirmed, err = confirmCommand(c.Command.Name, message, cmd.Confirm, os.Stdin, os.Stderr, isTerminal(os.Stdin)); err != nil {
+						return false, err
+					}
+					if !confirmed {
+						fmt.Fprintln(os.Stderr, "The operation was canceled.")
+					}
+					return confirmed, nil
+				}
+				if !replaying {
+					if ok, err := confirm(); err != nil || !ok {
+						return err
+					}
 				}
-				command := exec.Command(cmdItems[0], cmdItems[1:]...)
-				command.Dir = AhoyConf.srcDir
-				command.Stdout = os.Stdout
-				command.Stdin = os.Stdin
-				command.Stderr = os.Stderr
-				command.Env = append(command.Environ(), envVars...)
-				if err := command.Run(); err != nil {
-					fmt.Fprintln(os.Stderr)
-					os.Exit(1)
+
+				// When the command is watched, this runs each time its files change.
+				run := func() error {
+					if replaying {
+						// Later runs of a watched command run it again.
+						replaying = false
+						if verbose {
+							log.Println("===> Ahoy replaying the output of", name, "cached at", cached.Created.Format(time.RFC3339))
+						}
+						os.Stdout.Write(cached.Stdout)
+						if cached.ExitCode != 0 {
+							return &exitStatus{code: cached.ExitCode}
+						}
+						return nil
+					}
+					if ok, err := confirm(); err != nil || !ok {
+						return err
+					}
+
+					fingerprint := ""
+					if len(cmd.Sources) > 0 {
+						var err error
+						if fingerprint, err = sourcesFingerprint(srcDir, cmd, cmdArgs); err != nil {
+							return errors.New("Could not check the sources of '" + c.Command.Name + "': " + err.Error())
+						}
+						unmatched, matched, err := unmatchedSources(srcDir, cmd.Sources)
+						if err != nil {
+							return errors.New("Could not check the sources of '" + c.Command.Name + "': " + err.Error())
+						}
+						for _, pattern := range unmatched {
+							logger("warn", "The source '"+pattern+"' of '"+fullName+"' doesn't match any files.")
+						}
+						upToDate, err := isUpToDate(srcDir, fullName, fingerprint, cmd.Generates)
+						if err != nil {
+							return errors.New("Could not check what '" + c.Command.Name + "' generates: " + err.Error())
+						}
+						// Without any sources, there's nothing to tell whether it changed.
+						if upToDate && matched && !force {
+							logger("info", "Command '"+c.Command.Name+"' is up to date. Use --force to run it anyway.")
+							return nil
+						}
+					}
+
+					if lock := lockName(cmd.Lock, fullName); lock != "" {
+						release, err := acquireLock(srcDir, lock, fullName)
+						if err != nil {
+							return err
+						}
+						defer release()
+					}
+
+					for attempt := 1; ; attempt++ {
+						// A command can only be started once, so each attempt needs a new one.
+						command := exec.Command(cmdItems[0], cmdItems[1:]...)
+						command.Dir = runDir
+						command.Stdout = os.Stdout
+						command.Stdin = os.Stdin
+						command.Stderr = os.Stderr
+						command.Env = append(command.Environ(), envVars...)
+						var stdout bytes.Buffer
+						if cacheFile != "" {
+							command.Stdout = io.MultiWriter(os.Stdout, &stdout)
+						}
+						err := runCommand(command, runTimeout)
+						status := commandStatus(c.Command.Name, err)
+						code, _ := exitCode(status)
+						// Only the last attempt is cached when the command is retried.
+						if cacheFile != "" && isCacheable(err, status) && !retry.retries(err, code, attempt) {
+							result := cachedResult{Command: fullName, Stdout: stdout.Bytes(), ExitCode: code, Created: time.Now()}
+							if err := saveCachedResult(cacheFile, result); err != nil {
+								logger("warn", "Could not cache the output of '"+c.Command.Name+"': "+err.Error())
+							}
+						}
+						if err == nil {
+							if fingerprint != "" {
+								if err := saveFingerprint(srcDir, fullName, fingerprint); err != nil {
+									logger("warn", "Could not save the fingerprint of '"+c.Command.Name+"': "+err.Error())
+								}
+							}
+							return nil
+						}
+						if retry.retries(err, code, attempt) && waitToRetry(c.Command.Name, code, attempt, retry) {
+							continue
+						}
+						fmt.Fprintln(os.Stderr)
+						if cmd.Cleanup != "" {
+							runCleanup(command, entrypointCommand(config.Entrypoint, cmd.Cleanup, c.Command.Name, cmdArgs), code)
+						}
+						return status
+					}
 				}
+
+				if watch := claimWatch(); watch != nil {
+					globs := cmd.Watch
+					if len(globs) == 0 {
+						globs = cmd.Sources
+					}
+					if len(globs) == 0 {
+						return errors.New("Command '" + c.Command.Name + "' has no 'watch' or 'sources' files to watch.")
+					}
+					return watch.run(c.Command.Name, srcDir, globs, run)
+				}
+				return run()
 			}
 		}
 
+		source := newCommandSource(cmd)
 		if cmd.Imports != nil {
-			subCommands := getSubCommands(cmd.Imports)
+			subCommands, subSources, err := loadSubCommands(cmd.Imports, path)
+			if err != nil {
+				return nil, nil, err
+			}
 			if len(subCommands) == 0 {
 				if !cmd.Optional {
-					logger("fatal", "Command ["+name+"] has 'imports' set, but no commands were found. Check your yaml file.")
+					return nil, nil, commandError(cmd, "Command ["+name+"] has 'imports' set, but no commands were found. Check your yaml file.")
 				} else {
 					continue
 				}
 			}
 			newCmd.Subcommands = subCommands
+			source.subcommands = subSources
 		}
 
 		// log.Println("Source file:", sourcefile, "- found command:", name, ">", cmd.Cmd)
 		exportCmds = append(exportCmds, newCmd)
+		sources[name] = source
 	}
 
-	return exportCmds
+	return exportCmds, sources, nil
 }
 
 func addDefaultCommands(commands []cli.Command) []cli.Command {
@@ -350,7 +726,7 @@ func addDefaultCommands(commands []cli.Command) []cli.Command {
 				Usage: "force overwriting the .ahoy.yml file in the current directory.",
 			},
 		},
-		Action: func(c *cli.Context) {
+		Action: refuseDryRun(func(c *cli.Context) error {
 			if fileExists(filepath.Join(".", ".ahoy.yml")) {
 				if c.Bool("force") {
 					fmt.Println("Warning: '--force' parameter passed, overwriting .ahoy.yml in current directory.")
@@ -366,7 +742,7 @@ func addDefaultCommands(commands []cli.Command) []cli.Command {
 					// Anything else, exit.
 					if char != 'y' && char != 'Y' {
 						fmt.Println("Abort: exiting without overwriting.")
-						os.Exit(0)
+						return nil
 					}
 					if len(c.Args()) > 0 {
 						fmt.Println("Ok, overwriting .ahoy.yml in current directory with specified file.")
@@ -388,7 +764,7 @@ func addDefaultCommands(commands []cli.Command) []cli.Command {
 			cmd.Stderr = os.Stderr
 			if err := cmd.Run(); err != nil {
 				fmt.Fprintln(os.Stderr)
-				os.Exit(1)
+				return commandExitStatus(err)
 			} else {
 				if len(c.Args()) > 0 {
 					fmt.Println("Your specified .ahoy.yml has been downloaded to the current directory.")
@@ -396,12 +772,153 @@ func addDefaultCommands(commands []cli.Command) []cli.Command {
 					fmt.Println("Example .ahoy.yml downloaded to the current directory. You can customize it to suit your needs!")
 				}
 			}
+			return nil
+		}),
+	}
+
+	defaultSignCmd := cli.Command{
+		Name:      "sign",
+		Usage:     "Sign ahoy files so they can be imported when trusted keys are configured.",
+		ArgsUsage: "<file>...",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "the ed25519 private key to sign with, created if it doesn't exist. Defaults to ~/.config/ahoy/signing.key",
+			},
+		},
+		Action: refuseDryRun(signAction),
+	}
+
+	defaultTrustCmd := cli.Command{
+		Name:  "trust",
+		Usage: "Trust the current .ahoy.yml and its imports, so their commands can be run.",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "revoke",
+				Usage: "stop trusting the current .ahoy.yml and its imports.",
+			},
+		},
+		Action: refuseDryRun(trustAction),
+	}
+
+	defaultMigrateCmd := cli.Command{
+		Name:      "migrate",
+		Usage:     "Upgrade an ahoy file to a newer API version, keeping its comments.",
+		ArgsUsage: "[file]",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "only print the changes, without writing them.",
+			},
+			cli.StringFlag{
+				Name:  "to",
+				Value: defaultMigrateAPI,
+				Usage: "the API version to upgrade to. Older releases of ahoy can't load v3 files.",
+			},
+		},
+		Action: migrateAction,
+	}
+
+	defaultImportsCmd := cli.Command{
+		Name:  "imports",
+		Usage: "Manage remote imports.",
+		Subcommands: []cli.Command{
+			{
+				Name:   "update",
+				Usage:  "Download remote imports again and accept any changed content.",
+				Action: refuseDryRun(updateImportsAction),
+			},
+		},
+	}
+
+	defaultDoctorCmd := cli.Command{
+		Name:   "doctor",
+		Usage:  "Check the tools, env files, imports and trust your ahoy files need.",
+		Action: doctorAction,
+	}
+
+	defaultWatchCmd := cli.Command{
+		Name:      "watch",
+		Usage:     "Run a command, and run it again whenever its 'watch' or 'sources' files change.",
+		ArgsUsage: "<command> [args...]",
+		// Flags after the command's name are passed on to it.
+		SkipArgReorder: true,
+		Flags: []cli.Flag{
+			cli.DurationFlag{
+				Name:  "interval",
+				Usage: "how often to check the files for changes.",
+				Value: defaultWatchInterval,
+			},
+			cli.DurationFlag{
+				Name:  "debounce",
+				Usage: "how long files must stay unchanged before the command runs again.",
+				Value: defaultWatchDebounce,
+			},
+		},
+		Action: watchAction,
+	}
+
+	defaultExplainCmd := cli.Command{
+		Name:      "explain",
+		Usage:     "Show what a command would run, where, and with which env files and variables, without running it.",
+		ArgsUsage: "<command> [args...]",
+		// Flags after the command's name are passed on to it.
+		SkipArgReorder: true,
+		Action:         explainAction,
+	}
+
+	defaultWhichCmd := cli.Command{
+		Name:      "which",
+		Usage:     "Show where a command is defined, which files imported it and what it overrides.",
+		ArgsUsage: "<command> [subcommand]...",
+		Action:    whichAction,
+	}
+
+	defaultListCmd := cli.Command{
+		Name:  "list",
+		Usage: "List all commands, including imported subcommands and where they're defined, as a tree, JSON or YAML.",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "tree",
+				Usage: "show the commands as a tree, which is the default.",
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "show the commands as JSON.",
+			},
+			cli.BoolFlag{
+				Name:  "yaml",
+				Usage: "show the commands as YAML.",
+			},
+		},
+		Action: listAction,
+	}
+
+	defaultCacheCmd := cli.Command{
+		Name:  "cache",
+		Usage: "Manage the cached output of commands.",
+		Subcommands: []cli.Command{
+			{
+				Name:      "clear",
+				Usage:     "Remove the cached output of the commands given, or of all commands.",
+				ArgsUsage: "[command]...",
+				Action:    refuseDryRun(cacheClearAction),
+			},
 		},
 	}
 
+	defaultCompletionCmd := cli.Command{
+		Name:      "completion",
+		Usage:     "Print a script that completes commands, subcommands, inputs and arguments in bash, zsh, fish or PowerShell.",
+		ArgsUsage: strings.Join(completionShells, "|"),
+		Action:    completionAction,
+	}
+
 	// Don't add default commands if they've already been set.
-	if c := app.Command(defaultInitCmd.Name); c == nil {
-		commands = append(commands, defaultInitCmd)
+	for _, defaultCmd := range []cli.Command{defaultInitCmd, defaultTrustCmd, defaultMigrateCmd, defaultImportsCmd, defaultSignCmd, defaultDoctorCmd, defaultWatchCmd, defaultCacheCmd, defaultExplainCmd, defaultWhichCmd, defaultListCmd, defaultCompletionCmd} {
+		if c := app.Command(defaultCmd.Name); c == nil {
+			commands = append(commands, defaultCmd)
+		}
 	}
 	return commands
 }
@@ -414,15 +931,15 @@ func init() {
 	flag.BoolVar(&verbose, "verbose", false, "")
 }
 
-// BashComplete prints the list of subcommands as the default app completion method
+// BashComplete prints the list of subcommands as the default app completion method.
+// 'ahoy completion' gives scripts that complete subcommands, inputs and arguments too.
 func BashComplete(c *cli.Context) {
 	logger("debug", "BashComplete()")
 
-	if sourcefile != "" {
-		log.Println(sourcefile)
-		os.Exit(0)
-	}
 	for _, command := range c.App.Commands {
+		if command.HideHelp || command.Hidden {
+			continue
+		}
 		for _, name := range command.Names() {
 			fmt.Fprintln(c.App.Writer, name)
 		}
@@ -432,11 +949,11 @@ func BashComplete(c *cli.Context) {
 // NoArgsAction is the application wide default action, for when no flags or arguments
 // are passed or when a command doesn't exist.
 // Looks like -f flag still works through here though.
-func NoArgsAction(c *cli.Context) {
+func NoArgsAction(c *cli.Context) error {
 	args := c.Args()
 	if len(args) > 0 {
 		msg := "Command not found for '" + strings.Join(args, " ") + "'"
-		logger("fatal", msg)
+		return newAhoyError(exitCommandNotFound, errors.New(msg))
 	}
 
 	cli.ShowAppHelp(c)
@@ -447,11 +964,11 @@ func NoArgsAction(c *cli.Context) {
 
 	if !c.Bool("help") || !c.Bool("version") {
 		logger("warn", "Missing flag or argument.")
-		os.Exit(1)
+		return &exitStatus{code: exitFailure}
 	}
 
 	// Exit gracefully if we get to here.
-	os.Exit(0)
+	return nil
 }
 
 // BeforeCommand runs before every command so arguments or flags must be passed
@@ -460,7 +977,7 @@ func BeforeCommand(c *cli.Context) error {
 	// fmt.Printf("%+v\n", args)
 	if c.Bool("version") {
 		fmt.Println(version)
-		return errors.New("don't continue with commands")
+		return errStopCommands
 	}
 	if c.Bool("help") {
 		if len(args) > 0 {
@@ -468,15 +985,21 @@ func BeforeCommand(c *cli.Context) error {
 		} else {
 			cli.ShowAppHelp(c)
 		}
-		return errors.New("don't continue with commands")
+		return errStopCommands
 	}
 	// fmt.Printf("%+v\n", args)
 	return nil
 }
 
-func setupApp(localArgs []string) *cli.App {
+// setupApp loads the ahoy files and builds the app to run. Errors loading the
+// config are returned, with the code ahoy should exit with.
+func setupApp(localArgs []string) (*cli.App, error) {
 	var err error
-	initFlags(localArgs)
+	args := initFlags(localArgs)
+	// Changed remote imports are only accepted when updating them explicitly,
+	// which has to be known before the config is loaded.
+	AhoyConf.updateImports = len(args) > 1 && args[0] == "imports" && args[1] == "update" && !dryRun
+	remoteImportsFetched = nil
 	// cli stuff
 	app = cli.NewApp()
 	app.Action = NoArgsAction
@@ -490,31 +1013,72 @@ func setupApp(localArgs []string) *cli.App {
 
 	AhoyConf.srcFile, err = getConfigPath(sourcefile)
 	if err != nil {
-		logger("fatal", err.Error())
-	} else {
-		AhoyConf.srcDir = filepath.Dir(AhoyConf.srcFile)
-		// If we don't have a sourcefile, then just supply the default commands.
-		if AhoyConf.srcFile == "" {
-			app.Commands = addDefaultCommands(app.Commands)
-			app.Run(os.Args)
-			os.Exit(0)
-		}
-		config, err := getConfig(AhoyConf.srcFile)
+		return nil, configError(err)
+	}
+	AhoyConf.srcDir = filepath.Dir(AhoyConf.srcFile)
+
+	// User commands are available everywhere, even without an .ahoy.yml.
+	userFile, userConfig, err := getUserConfig()
+	if err != nil {
+		return nil, configError(err)
+	}
+	keysConfig := Config{TrustedKeys: userConfig.TrustedKeys}
+
+	// If we don't have a sourcefile, then just supply the user and default commands.
+	if AhoyConf.srcFile != "" {
+		config, err := getConfigWithOverlay(AhoyConf.srcFile)
+		if err != nil {
+			return nil, configError(err)
+		}
+		config, err = mergeSourcefiles(config)
 		if err != nil {
-			logger("fatal", err.Error())
+			return nil, configError(err)
+		}
+		levels, err := getConfigHierarchy(AhoyConf.srcFile, config)
+		if err != nil {
+			return nil, configError(err)
+		}
+		AhoyConf.configLevels = levels
+		// Imports must be signed by a key trusted at any level of the hierarchy.
+		for _, level := range levels {
+			if level.file != stdinConfigFile {
+				trackConfigFile(level.file)
+			}
+			keysConfig.TrustedKeys = append(keysConfig.TrustedKeys, level.config.TrustedKeys...)
+		}
+		AhoyConf.trustedKeys, err = loadTrustedKeys(keysConfig)
+		if err != nil {
+			return nil, configError(err)
+		}
+		app.Commands, err = getHierarchyCommands(levels)
+		if err != nil {
+			return nil, err
 		}
-		app.Commands = getCommands(config)
-		app.Commands = addDefaultCommands(app.Commands)
 		if config.Usage != "" {
 			app.Usage = config.Usage
 		}
+	} else {
+		AhoyConf.trustedKeys, err = loadTrustedKeys(keysConfig)
+		if err != nil {
+			return nil, configError(err)
+		}
 	}
+	if userFile != "" {
+		AhoyConf.configLevels = append(AhoyConf.configLevels, configLevel{file: userFile, config: userConfig})
+	}
+	userCommands, err := getUserCommands(userFile, userConfig, app.Commands)
+	if err != nil {
+		return nil, err
+	}
+	app.Commands = append(app.Commands, userCommands...)
+	app.Commands = addDefaultCommands(app.Commands)
 
 	// Set up custom help printer with additional template functions.
 	cli.HelpPrinterCustom = func(out io.Writer, templ string, data any, customFuncs map[string]any) {
 		funcMap := template.FuncMap{
-			"join":    strings.Join,
-			"replace": strings.ReplaceAll,
+			"join":      strings.Join,
+			"replace":   strings.ReplaceAll,
+			"definedIn": sourceOfHelpName,
 		}
 		for key, value := range customFuncs {
 			funcMap[key] = value
@@ -543,7 +1107,7 @@ AUTHOR(S):
    {{range .Authors}}{{ . }}{{end}}
    {{end}}{{if .Commands}}
 COMMANDS:
-{{range .Commands}}{{if not .HideHelp}}   {{join .Names ", "}}{{ if len .Subcommands }}{{" \u25BC"}}{{end}}{{ "\t" }}{{.Usage}}{{if .Description}}{{ "\n" }}{{ "\n" }}{{ "\t" }}{{replace .Description "\n" "\n\t"}}{{ "\n" }}{{end}} {{if .Aliases}}[ Aliases: {{join .Aliases ", "}} ]{{end}}{{ "\n" }}{{end}}{{end}}{{end}}{{if .Flags}}
+{{range .VisibleCategories}}{{if .Name}}{{ "\n" }}  {{.Name}}:{{ "\n" }}{{end}}{{range .Commands}}{{if not .HideHelp}}   {{join .Names ", "}}{{ if len .Subcommands }}{{" \u25BC"}}{{end}}{{ "\t" }}{{.Usage}}{{if .Description}}{{ "\n" }}{{ "\n" }}{{ "\t" }}{{replace .Description "\n" "\n\t"}}{{ "\n" }}{{end}} {{if .Aliases}}[ Aliases: {{join .Aliases ", "}} ]{{end}}{{ "\n" }}{{end}}{{end}}{{end}}{{end}}{{if .Flags}}
 GLOBAL OPTIONS:
    {{range .Flags}}{{.}}
    {{end}}{{end}}{{if .Copyright }}
@@ -558,11 +1122,64 @@ ALIASES:
     You can use any of a command's aliases interchangeably with its primary name.
 `
 
-	return app
+	// The help for a command, and for a command with subcommands, also shows
+	// where it's defined.
+	cli.CommandHelpTemplate = `NAME:
+   {{.HelpName}} - {{.Usage}}
+
+USAGE:
+   {{if .UsageText}}{{.UsageText}}{{else}}{{.HelpName}}{{if .VisibleFlags}} [command options]{{end}} {{if .ArgsUsage}}{{.ArgsUsage}}{{else}}[arguments...]{{end}}{{end}}{{if .Category}}
+
+CATEGORY:
+   {{.Category}}{{end}}{{if .Description}}
+
+DESCRIPTION:
+   {{.Description}}{{end}}{{with definedIn .HelpName}}
+
+DEFINED IN:
+   {{.}}{{end}}{{if .VisibleFlags}}
+
+OPTIONS:
+   {{range .VisibleFlags}}{{.}}
+   {{end}}{{end}}
+`
+	cli.SubcommandHelpTemplate = `NAME:
+   {{.HelpName}} - {{if .Description}}{{.Description}}{{else}}{{.Usage}}{{end}}
+
+USAGE:
+   {{if .UsageText}}{{.UsageText}}{{else}}{{.HelpName}} command{{if .VisibleFlags}} [command options]{{end}} {{if .ArgsUsage}}{{.ArgsUsage}}{{else}}[arguments...]{{end}}{{end}}
+
+COMMANDS:{{range .VisibleCategories}}{{if .Name}}
+
+   {{.Name}}:{{range .VisibleCommands}}
+     {{join .Names ", "}}{{"\t"}}{{.Usage}}{{end}}{{else}}{{range .VisibleCommands}}
+   {{join .Names ", "}}{{"\t"}}{{.Usage}}{{end}}{{end}}{{end}}{{with definedIn .HelpName}}
+
+DEFINED IN:
+   {{.}}{{end}}{{if .VisibleFlags}}
+
+OPTIONS:
+   {{range .VisibleFlags}}{{.}}
+   {{end}}{{end}}
+`
+
+	return app, nil
 }
 
 func main() {
 	logger("debug", "main()")
-	app = setupApp(os.Args[1:])
-	app.Run(os.Args)
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == completeCommand {
+		printCompletions(os.Stdout, os.Args[2:])
+		return
+	}
+	app, err = setupApp(os.Args[1:])
+	if err == nil {
+		err = app.Run(os.Args)
+	}
+	code, show := exitCode(err)
+	if show {
+		logger("fatal", err.Error())
+	}
+	os.Exit(code)
 }
@@ -10,6 +10,13 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+func TestMain(m *testing.M) {
+	// The tests run commands from testdata, which would otherwise need to be
+	// trusted first.
+	os.Setenv("AHOY_TRUST_ALL", "1")
+	os.Exit(m.Run())
+}
+
 func TestOverrideExample(t *testing.T) {
 	// Override a command with the same command from another imported command file.
 	expected := "Overrode you.\n"
@@ -34,7 +41,7 @@ func TestGetCommands(t *testing.T) {
 		},
 	}
 
-	commands := getCommands(config)
+	commands, _ := getCommands(config)
 
 	if len(commands) != 1 {
 		t.Error("Expect that getCommands can get one command if passed config with one command.")
@@ -48,14 +55,14 @@ func TestGetSubCommand(t *testing.T) {
 
 	// When empty return empty list of commands.
 
-	actual := getSubCommands([]string{})
+	actual, _ := getSubCommands([]string{})
 
 	if len(actual) != 0 {
 		t.Error("Expect that getSubCommands([]string) returns []Command{}")
 	}
 
 	// List of bogus or empty strings returns empty list of commands.
-	actual = getSubCommands([]string{
+	actual, _ = getSubCommands([]string{
 		"./testing/bogus1.ahoy.yml",
 		"./testing/private.ahoy.yml",
 	})
@@ -108,7 +115,7 @@ commands:
 		t.Error("Error writing to file2.")
 	}
 
-	actual = getSubCommands([]string{
+	actual, _ = getSubCommands([]string{
 		"./testing/a.ahoy.yml",
 		"./testing/b.ahoy.yml",
 	})
@@ -143,7 +150,7 @@ commands:
 		t.Error("Error writing to file3.")
 	}
 
-	actual = getSubCommands([]string{
+	actual, _ = getSubCommands([]string{
 		"./testing/a.ahoy.yml",
 		"./testing/b.ahoy.yml",
 		"./testing/c.ahoy.yml",
@@ -240,8 +247,9 @@ func appRun(args []string) (string, error) {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	setupApp(args[1:])
-	app.Run(args)
+	if _, err := setupApp(args[1:]); err == nil {
+		app.Run(args)
+	}
 
 	w.Close()
 	//@aashil thinks this reads from the command line
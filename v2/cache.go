@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// Cache replays a command's output instead of running it again, for commands
+// that print information that is slow to work out. It can be written as just
+// the TTL, or as a map:
+//
+//	cache:
+//	  ttl: 5m
+//	  key: $DOCKER_HOST
+type Cache struct {
+	// TTL is how long a result is replayed for, such as "30s".
+	TTL string
+	// Key is added to what results are cached by, after expanding any
+	// environment variables in it.
+	Key string
+}
+
+// UnmarshalYAML allows a cache to be written as just its TTL.
+func (c *Cache) UnmarshalYAML(unmarshal func(any) error) error {
+	var ttl string
+	if err := unmarshal(&ttl); err == nil {
+		*c = Cache{TTL: ttl}
+		return nil
+	}
+	type plain Cache
+	return unmarshal((*plain)(c))
+}
+
+// parseCacheTTL checks a command's cache, returning 0 for commands without one.
+func parseCacheTTL(cache *Cache) (time.Duration, error) {
+	if cache == nil {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(cache.TTL)
+	if err != nil || ttl <= 0 {
+		return 0, errors.New("the ttl '" + cache.TTL + "' isn't a duration such as '5m'")
+	}
+	return ttl, nil
+}
+
+// cachedResult is what's kept of a command's run.
+type cachedResult struct {
+	Command  string    `json:"command"`
+	Stdout   []byte    `json:"stdout"`
+	ExitCode int       `json:"exit_code"`
+	Created  time.Time `json:"created"`
+}
+
+func resultsCacheDir() (string, error) {
+	cacheDir, err := ahoyCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "results"), nil
+}
+
+// resultCachePath returns where a command's result is cached. Results are
+// kept by the command's full name and script, its arguments, where it runs,
+// the variables from its env files and its cache key, so a change to any of
+// them runs it again.
+func resultCachePath(name string, cmd Command, args []string, dir string, envVars []string) (string, error) {
+	cacheDir, err := resultsCacheDir()
+	if err != nil {
+		return "", err
+	}
+	key := os.Expand(cmd.Cache.Key, func(name string) string {
+		for i := len(envVars) - 1; i >= 0; i-- {
+			if value, found := strings.CutPrefix(envVars[i], name+"="); found {
+				return value
+			}
+		}
+		return os.Getenv(name)
+	})
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%q\n%q\n%q\n%q\n%q\n%q\n", name, cmd.Cmd, args, dir, envVars, key)
+	return filepath.Join(cacheDir, hex.EncodeToString(hash.Sum(nil))+".json"), nil
+}
+
+// readCachedResult returns a cached result if it's younger than the TTL.
+func readCachedResult(file string, ttl time.Duration) (cachedResult, bool) {
+	result := cachedResult{}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return result, false
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, false
+	}
+	return result, time.Since(result.Created) < ttl
+}
+
+func saveCachedResult(file string, result cachedResult) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0600)
+}
+
+// isCacheable reports whether a run's result can be replayed. Runs that were
+// interrupted, timed out or couldn't start are run again next time.
+func isCacheable(err error, status error) bool {
+	var interrupted *interruptedError
+	var exit *exitStatus
+	if err == nil {
+		return true
+	}
+	return !errors.As(err, &interrupted) && errors.As(status, &exit) && exit.code < 128
+}
+
+// clearCachedResults removes the cached results of the named commands, or of
+// all commands when no names are given, returning how many were removed.
+func clearCachedResults(names []string) (int, error) {
+	cacheDir, err := resultsCacheDir()
+	if err != nil {
+		return 0, err
+	}
+	files, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, file := range files {
+		if len(names) > 0 {
+			result := cachedResult{}
+			data, err := os.ReadFile(file)
+			if err == nil && json.Unmarshal(data, &result) == nil && !slices.Contains(names, result.Command) {
+				continue
+			}
+		}
+		if err := os.Remove(file); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func cacheClearAction(c *cli.Context) error {
+	removed, err := clearCachedResults(c.Args())
+	if err != nil {
+		return errors.New("Could not clear the cache: " + err.Error())
+	}
+	fmt.Printf("Removed %d cached results.\n", removed)
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+func TestCacheUnmarshal(t *testing.T) {
+	var config Config
+	data := `
+ahoyapi: v2
+commands:
+  ip:
+    cmd: docker inspect web
+    cache: 5m
+  envs:
+    cmd: platform environments
+    cache:
+      ttl: 1h
+      key: $PROJECT
+`
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	if cache := config.Commands["ip"].Cache; cache == nil || cache.TTL != "5m" {
+		t.Errorf("Expected a string to set the TTL, got %+v", cache)
+	}
+	if cache := config.Commands["envs"].Cache; cache == nil || cache.TTL != "1h" || cache.Key != "$PROJECT" {
+		t.Errorf("Expected the cache map to be read, got %+v", cache)
+	}
+
+	for _, ttl := range []string{"", "soon", "-1m", "0s"} {
+		if _, err := parseCacheTTL(&Cache{TTL: ttl}); err == nil {
+			t.Errorf("Expected the TTL %q to be invalid", ttl)
+		}
+	}
+}
+
+func TestResultCachePath(t *testing.T) {
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	t.Setenv("AHOY_TEST_PROJECT", "one")
+	cmd := Command{Cmd: "status", Cache: &Cache{TTL: "1m", Key: "$AHOY_TEST_PROJECT"}}
+
+	path, _ := resultCachePath("status", cmd, []string{"web"}, "/app", nil)
+	if same, _ := resultCachePath("status", cmd, []string{"web"}, "/app", nil); same != path {
+		t.Error("Expected the same command to use the same cache")
+	}
+	different := map[string]func() (string, error){
+		"command":   func() (string, error) { return resultCachePath("docker status", cmd, []string{"web"}, "/app", nil) },
+		"arguments": func() (string, error) { return resultCachePath("status", cmd, []string{"db"}, "/app", nil) },
+		"directory": func() (string, error) { return resultCachePath("status", cmd, []string{"web"}, "/other", nil) },
+		"env files": func() (string, error) {
+			return resultCachePath("status", cmd, []string{"web"}, "/app", []string{"A=1"})
+		},
+		"key": func() (string, error) {
+			return resultCachePath("status", cmd, []string{"web"}, "/app", []string{"AHOY_TEST_PROJECT=two"})
+		},
+	}
+	for name, other := range different {
+		if otherPath, _ := other(); otherPath == path {
+			t.Errorf("Expected different %s to use a different cache", name)
+		}
+	}
+}
+
+func TestCachedResults(t *testing.T) {
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	dir, _ := resultsCacheDir()
+	fresh := filepath.Join(dir, "fresh.json")
+	saveCachedResult(fresh, cachedResult{Command: "ip", Stdout: []byte("10.0.0.1\n"), ExitCode: 0, Created: time.Now()})
+	saveCachedResult(filepath.Join(dir, "old.json"), cachedResult{Command: "status", ExitCode: 3, Created: time.Now().Add(-time.Hour)})
+
+	if result, found := readCachedResult(fresh, time.Minute); !found || string(result.Stdout) != "10.0.0.1\n" {
+		t.Errorf("Expected the result to be replayed within its TTL, got %+v", result)
+	}
+	if _, found := readCachedResult(filepath.Join(dir, "old.json"), time.Minute); found {
+		t.Error("Expected a result older than its TTL not to be replayed")
+	}
+
+	if removed, err := clearCachedResults([]string{"status"}); err != nil || removed != 1 {
+		t.Errorf("Expected one result to be removed, got %d: %v", removed, err)
+	}
+	if removed, err := clearCachedResults(nil); err != nil || removed != 1 {
+		t.Errorf("Expected the other result to be removed, got %d: %v", removed, err)
+	}
+}
+
+func TestIsCacheable(t *testing.T) {
+	failed := errors.New("exit status 3")
+	if !isCacheable(nil, nil) || !isCacheable(failed, &exitStatus{code: 3}) {
+		t.Error("Expected commands that exit by themselves to be cached")
+	}
+	if isCacheable(failed, &exitStatus{code: 137}) {
+		t.Error("Expected commands killed by a signal not to be cached")
+	}
+	if isCacheable(&interruptedError{signal: syscall.SIGTERM, err: failed}, &exitStatus{code: 0}) {
+		t.Error("Expected interrupted commands not to be cached")
+	}
+	if isCacheable(failed, newAhoyError(exitTimeout, failed)) {
+		t.Error("Expected commands that timed out not to be cached")
+	}
+}
+
+func TestCacheCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses bash to count runs")
+	}
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	defer func(f bool) { force = f }(force)
+	runs := filepath.Join(t.TempDir(), "runs")
+
+	config := Config{Entrypoint: []string{"bash", "-c", "{{cmd}}", "{{name}}"}, Commands: map[string]Command{
+		"status": {Cmd: `echo run >> "` + runs + `"; exit 3`, Cache: &Cache{TTL: "1m"}},
+	}}
+	commands, err := getCommands(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := cli.NewApp()
+	app.Commands = commands
+	run := func() (int, int) {
+		code, _ := exitCode(app.Run([]string{"ahoy", "status"}))
+		data, _ := os.ReadFile(runs)
+		return code, strings.Count(string(data), "run")
+	}
+
+	if code, count := run(); code != 3 || count != 1 {
+		t.Errorf("Expected the command to run the first time, got exit code %d and %d runs", code, count)
+	}
+	if code, count := run(); code != 3 || count != 1 {
+		t.Errorf("Expected the cached exit code to be replayed, got exit code %d and %d runs", code, count)
+	}
+	force = true
+	if _, count := run(); count != 2 {
+		t.Errorf("Expected --force to run the command anyway, got %d runs", count)
+	}
+}
+
+func TestCachedCommandIsNotConfirmed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses bash to run the command")
+	}
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	t.Setenv("AHOY_CONFIRM_RESPONSE", "")
+	defer func(yes bool) { assumeYes = yes }(assumeYes)
+
+	config := Config{Entrypoint: []string{"bash", "-c", "{{cmd}}", "{{name}}"}, Commands: map[string]Command{
+		"status": {Cmd: "exit 3", Cache: &Cache{TTL: "1m"}, Confirm: &Confirm{Message: "Check the status?"}},
+	}}
+	commands, err := getCommands(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := cli.NewApp()
+	app.Commands = commands
+
+	assumeYes = true
+	if code, _ := exitCode(app.Run([]string{"ahoy", "status"})); code != 3 {
+		t.Fatalf("Expected the command to run once confirmed, got exit code %d", code)
+	}
+	// Tests don't run in a terminal, so asking again would fail.
+	assumeYes = false
+	if code, _ := exitCode(app.Run([]string{"ahoy", "status"})); code != 3 {
+		t.Errorf("Expected the cached result to be replayed without asking, got exit code %d", code)
+	}
+
+	// Once the result expires, the command is confirmed again before it runs.
+	os.RemoveAll(os.Getenv("AHOY_CACHE_DIR"))
+	if err := app.Run([]string{"ahoy", "status"}); err == nil || !strings.Contains(err.Error(), "needs to be confirmed") {
+		t.Errorf("Expected the command to need confirming without a cached result, got %v", err)
+	}
+}
@@ -22,6 +22,10 @@ func TestFlagParsing(t *testing.T) {
 			flagNames[f.Name] = true
 		case cli.StringFlag:
 			flagNames[f.Name] = true
+		case cli.StringSliceFlag:
+			flagNames[f.Name] = true
+		case cli.DurationFlag:
+			flagNames[f.Name] = true
 		}
 	}
 
@@ -196,7 +200,10 @@ func TestCLIAppConfiguration(t *testing.T) {
 	}()
 
 	// Test app setup
-	testApp := setupApp([]string{})
+	testApp, err := setupApp([]string{})
+	if err != nil {
+		t.Fatalf("setupApp returned an error: %v", err)
+	}
 	if testApp == nil {
 		t.Error("setupApp returned nil")
 		return
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// completeCommand is the hidden command the completion scripts run, with the
+// words typed after 'ahoy', to find the candidates for the last of them.
+const completeCommand = "__complete"
+
+// completionTimeout is how long a command's 'complete' snippet can run for.
+const completionTimeout = 5 * time.Second
+
+// completionShells are the shells 'ahoy completion' has scripts for.
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completion is a candidate for the word being completed, with a description
+// for the shells that show them.
+type completion struct {
+	value       string
+	description string
+}
+
+// completing is set while a command runs its 'complete' snippet for the word
+// being completed, instead of running its 'cmd'.
+var completing *completionRequest
+
+type completionRequest struct {
+	current    string
+	candidates []completion
+}
+
+// run runs a command's 'complete' snippet, offering each line it prints that
+// starts with the word being completed. A line can have a description after a
+// tab. Snippets only run from trusted files, as nobody is asked first.
+func (r *completionRequest) run(argv []string, dir string, envVars []string) {
+	if !filesTrusted() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	command := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	command.Dir = dir
+	command.Env = append(command.Environ(), envVars...)
+	command.Env = append(command.Env, "AHOY_COMPLETE_WORD="+r.current)
+	output, err := command.Output()
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		value, description, _ := strings.Cut(strings.TrimRight(line, "\r"), "\t")
+		if value != "" && strings.HasPrefix(value, r.current) {
+			r.candidates = append(r.candidates, completion{value: value, description: description})
+		}
+	}
+}
+
+// printCompletions prints the candidates for the last of the words typed
+// after 'ahoy', one per line with any description after a tab. Nothing is
+// printed when there's nothing to offer, so shells can complete files instead.
+func printCompletions(out io.Writer, words []string) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	typed, current := words[:len(words)-1], words[len(words)-1]
+
+	set := flagSet("completion", globalFlags)
+	set.SetOutput(io.Discard)
+	// The word being completed is a flag's value, such as the file given to -f.
+	if err := set.Parse(typed); err != nil {
+		return
+	}
+	// Warnings while loading the ahoy files would end up in the prompt, and
+	// waiting to download remote imports would hang it.
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	AhoyConf.cachedImportsOnly = true
+	defer func() { AhoyConf.cachedImportsOnly = false }()
+	app, err := setupApp(typed)
+	if err != nil {
+		return
+	}
+
+	for _, candidate := range completeWords(app, app.Commands, nil, set.Args(), current) {
+		description := strings.Join(strings.Fields(candidate.description), " ")
+		if description == "" {
+			fmt.Fprintln(out, candidate.value)
+		} else {
+			fmt.Fprintf(out, "%s\t%s\n", candidate.value, description)
+		}
+	}
+}
+
+// completeWords returns the candidates for the word being completed, after
+// args which start with a command, following its subcommands.
+func completeWords(app *cli.App, commands []cli.Command, path []string, args []string, current string) []completion {
+	for len(args) > 0 {
+		var command *cli.Command
+		for i := range commands {
+			if commands[i].HasName(args[0]) {
+				command = &commands[i]
+				break
+			}
+		}
+		if command == nil {
+			return nil
+		}
+		path, args = append(path, command.Name), args[1:]
+		if len(command.Subcommands) == 0 {
+			return completeArgs(app, command, path, args, current)
+		}
+		commands = command.Subcommands
+	}
+
+	if strings.HasPrefix(current, "-") {
+		if len(path) == 0 {
+			return flagCompletions(globalFlags, current)
+		}
+		return nil
+	}
+	candidates := []completion{}
+	for _, command := range commands {
+		if command.HideHelp || command.Hidden {
+			continue
+		}
+		for _, name := range command.Names() {
+			if strings.HasPrefix(name, current) {
+				candidates = append(candidates, completion{value: name, description: command.Usage})
+			}
+		}
+	}
+	return candidates
+}
+
+// completeArgs returns the candidates for an argument of a command. Commands
+// from ahoy files offer their inputs and the output of their 'complete'
+// snippet, while built in commands offer their flags, and the commands they
+// run or describe.
+func completeArgs(app *cli.App, command *cli.Command, path []string, args []string, current string) []completion {
+	if source, _ := findSource(path); source != nil {
+		if strings.HasPrefix(current, "-") {
+			return inputCompletions(source.cmd.Inputs, args, current)
+		}
+		if source.cmd.Complete == "" {
+			return nil
+		}
+		completing = &completionRequest{current: current}
+		defer func() { completing = nil }()
+		context := cli.NewContext(app, flag.NewFlagSet(app.Name, flag.ContinueOnError), nil)
+		invokeCommand(context, command, args)
+		return completing.candidates
+	}
+
+	commandArgs := []string{}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			commandArgs = append(commandArgs, arg)
+		}
+	}
+	switch {
+	case strings.HasPrefix(current, "-") && len(commandArgs) == 0:
+		return flagCompletions(command.Flags, current)
+	case command.Name == "completion" && len(path) == 1 && len(commandArgs) == 0:
+		candidates := []completion{}
+		for _, shell := range completionShells {
+			if strings.HasPrefix(shell, current) {
+				candidates = append(candidates, completion{value: shell})
+			}
+		}
+		return candidates
+	// Commands such as 'watch' and 'which' take another command.
+	case strings.HasPrefix(command.ArgsUsage, "<command>") || strings.HasPrefix(command.ArgsUsage, "[command]"):
+		return completeWords(app, app.Commands, nil, commandArgs, current)
+	}
+	return nil
+}
+
+// flagCompletions returns the long names of flags, apart from the hidden
+// --generate-bash-completion.
+func flagCompletions(flags []cli.Flag, current string) []completion {
+	candidates := []completion{}
+	for _, f := range flags {
+		listed := listFlag(f)
+		if listed.Name == "generate-bash-completion" {
+			continue
+		}
+		name := "--" + listed.Name
+		if len(listed.Name) == 1 {
+			name = "-" + listed.Name
+		}
+		if strings.HasPrefix(name, current) {
+			candidates = append(candidates, completion{value: name, description: listed.Usage})
+		}
+	}
+	return candidates
+}
+
+// inputCompletions returns the inputs of a command that haven't been given
+// yet, as --name= or --name for confirmations, and the choices of a select
+// input once its name has been typed.
+func inputCompletions(inputs []Input, args []string, current string) []completion {
+	candidates := []completion{}
+	if name, value, found := strings.Cut(strings.TrimPrefix(current, "--"), "="); found {
+		for _, input := range inputs {
+			if input.Name != name {
+				continue
+			}
+			for _, choice := range input.Choices {
+				if strings.HasPrefix(choice, value) {
+					candidates = append(candidates, completion{value: "--" + name + "=" + choice})
+				}
+			}
+		}
+		return candidates
+	}
+
+	given, _, _ := inputArguments(inputs, args)
+	for _, input := range inputs {
+		if _, found := given[input.Name]; found {
+			continue
+		}
+		name := "--" + input.Name + "="
+		if input.Type == "confirm" {
+			name = "--" + input.Name
+		}
+		if strings.HasPrefix(name, current) {
+			candidates = append(candidates, completion{value: name, description: input.prompt()})
+		}
+	}
+	return candidates
+}
+
+func completionAction(c *cli.Context) error {
+	script, found := completionScripts[c.Args().First()]
+	if !found || len(c.Args()) != 1 {
+		return errors.New("Usage: ahoy completion " + strings.Join(completionShells, "|"))
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// completionScripts ask ahoy for the candidates using 'ahoy __complete',
+// passing the words typed so far, and fall back to completing files when
+// there are none.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for ahoy. Add this to ~/.bashrc:
+#   source <(ahoy completion bash)
+
+_ahoy_completions() {
+    local line="${COMP_LINE:0:COMP_POINT}" cur="" candidate
+    local -a words
+    read -ra words <<< "$line"
+    if [[ "$line" != *[[:space:]] ]]; then
+        cur="${words[${#words[@]}-1]}"
+        unset 'words[${#words[@]}-1]'
+    fi
+    # Bash only replaces the part of the word after any '=' or ':'.
+    local prefix="${cur%"${cur##*[=:]}"}"
+    COMPREPLY=()
+    while IFS= read -r candidate; do
+        candidate="${candidate%%$'\t'*}"
+        if [[ "$candidate" == *= ]]; then
+            compopt -o nospace 2>/dev/null
+        fi
+        COMPREPLY+=("${candidate#"$prefix"}")
+    done < <("${words[0]}" __complete "${words[@]:1}" "$cur" 2>/dev/null)
+}
+
+complete -o default -F _ahoy_completions ahoy
+`,
+	"zsh": `#compdef ahoy
+# zsh completion for ahoy. Add this to ~/.zshrc:
+#   source <(ahoy completion zsh)
+# or save it as _ahoy in a directory in your $fpath.
+
+_ahoy() {
+  local -a candidates values suffixed
+  local candidate value
+  candidates=("${(@f)$(${words[1]} __complete "${(@)words[2,CURRENT-1]}" "${words[CURRENT]}" 2>/dev/null)}")
+  for candidate in "${candidates[@]}"; do
+    [[ -z "$candidate" ]] && continue
+    value="${${candidate%%$'\t'*}//:/\\:}"
+    if [[ "$candidate" == *$'\t'* ]]; then
+      value+=":${candidate#*$'\t'}"
+    fi
+    if [[ "${candidate%%$'\t'*}" == *= ]]; then
+      suffixed+=("$value")
+    else
+      values+=("$value")
+    fi
+  done
+  if (( ${#values} + ${#suffixed} == 0 )); then
+    _files
+    return
+  fi
+  (( ${#values} )) && _describe -t values 'ahoy' values
+  (( ${#suffixed} )) && _describe -t values 'ahoy' suffixed -S ''
+  return 0
+}
+
+if [[ "${zsh_eval_context[-1]}" == loadautofunc ]]; then
+  _ahoy "$@"
+else
+  compdef _ahoy ahoy
+fi
+`,
+	"fish": `# fish completion for ahoy. Save it with:
+#   ahoy completion fish > ~/.config/fish/completions/ahoy.fish
+
+function __ahoy_complete
+    set -l words (commandline -opc)
+    set -l current (commandline -ct)
+    set -l candidates ($words[1] __complete $words[2..-1] "$current" 2>/dev/null)
+    if test (count $candidates) -eq 0
+        __fish_complete_path "$current"
+        return
+    end
+    printf '%s\n' $candidates
+end
+
+complete -c ahoy -f -a '(__ahoy_complete)'
+`,
+	"powershell": `# PowerShell completion for ahoy. Add this to your $PROFILE:
+#   ahoy completion powershell | Out-String | Invoke-Expression
+
+Register-ArgumentCompleter -Native -CommandName ahoy -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements |
+        Where-Object { $_.Extent.EndOffset -lt $cursorPosition } |
+        ForEach-Object { $_.Extent.Text })
+    $current = $wordToComplete
+    if ($current -eq '' -and $PSVersionTable.PSVersion -lt [version]'7.3.0') {
+        # Older versions of PowerShell drop empty arguments.
+        $current = '""'
+    }
+    $arguments = @($words | Select-Object -Skip 1)
+    $candidates = @(& $words[0] __complete @arguments $current 2>$null)
+    foreach ($candidate in $candidates) {
+        $value, $description = $candidate -split [char]9, 2
+        if (-not $description) {
+            $description = $value
+        }
+        [System.Management.Automation.CompletionResult]::new($value, $value, 'ParameterValue', $description)
+    }
+}
+`,
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCompletionConfig(t *testing.T) string {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "backups"), 0755)
+	os.WriteFile(filepath.Join(dir, "backups", "monday.sql"), []byte{}, 0644)
+	os.WriteFile(filepath.Join(dir, "backups", "tuesday.sql"), []byte{}, 0644)
+	main := filepath.Join(dir, ".ahoy.yml")
+	os.WriteFile(main, []byte(`ahoyapi: v2
+commands:
+  build:
+    usage: Build the app
+    aliases: [b]
+    cmd: make
+  secret:
+    hide: true
+    cmd: echo secret
+  db:import:
+    usage: Import a backup
+    cmd: echo "$1"
+    complete: |
+      ls backups
+      echo "latest	The newest backup"
+  deploy:
+    usage: Deploy the site
+    cmd: ./deploy.sh
+    inputs:
+      - name: environment
+        type: select
+        choices: [staging, production]
+      - name: force
+        type: confirm
+  docker:
+    usage: Docker commands
+    imports: [docker.ahoy.yml]
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "docker.ahoy.yml"), []byte("ahoyapi: v2\ncommands:\n  up:\n    usage: Start the containers\n    cmd: docker compose up\n"), 0644)
+	return main
+}
+
+func TestPrintCompletions(t *testing.T) {
+	main := writeCompletionConfig(t)
+	tests := []struct {
+		words    []string
+		expected []string
+	}{
+		{[]string{"b"}, []string{"build\tBuild the app", "b\tBuild the app"}},
+		{[]string{"s"}, []string{"sign\tSign ahoy files so they can be imported when trusted keys are configured."}},
+		{[]string{"docker", ""}, []string{"up\tStart the containers"}},
+		{[]string{"db:import", ""}, []string{"monday.sql", "tuesday.sql", "latest\tThe newest backup"}},
+		{[]string{"db:import", "t"}, []string{"tuesday.sql"}},
+		{[]string{"deploy", "--"}, []string{"--environment=\tenvironment", "--force\tforce"}},
+		{[]string{"deploy", "--force", "--"}, []string{"--environment=\tenvironment"}},
+		{[]string{"deploy", "--environment=p"}, []string{"--environment=production"}},
+		{[]string{"build", ""}, nil},
+		{[]string{"--ver"}, []string{"--verbose\tOutput extra details like the commands to be run.", "--version\tprint the version"}},
+		{[]string{"which", "docker", "u"}, []string{"up\tStart the containers"}},
+		{[]string{"watch", "--"}, []string{"--interval\thow often to check the files for changes.", "--debounce\thow long files must stay unchanged before the command runs again."}},
+		{[]string{"completion", "f"}, []string{"fish"}},
+		{[]string{"missing", ""}, nil},
+		{[]string{"-f"}, nil},
+	}
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		printCompletions(out, append([]string{"-f", main}, test.words...))
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if out.Len() == 0 {
+			lines = nil
+		}
+		if strings.Join(lines, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("Expected %q to complete to %q, got %q", test.words, test.expected, lines)
+		}
+	}
+}
+
+func TestCompletionSnippetNeedsTrust(t *testing.T) {
+	main := writeCompletionConfig(t)
+	t.Setenv("AHOY_TRUST_ALL", "")
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	out := &bytes.Buffer{}
+	printCompletions(out, []string{"-f", main, "db:import", ""})
+	if out.Len() != 0 {
+		t.Errorf("Expected no candidates from an untrusted file, got %q", out.String())
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	for _, shell := range completionShells {
+		out, err := appRun([]string{"ahoy", "completion", shell})
+		if err != nil || !strings.Contains(out, "__complete") {
+			t.Errorf("Expected a %s script that runs 'ahoy __complete', got %v:\n%s", shell, err, out)
+		}
+	}
+	if len(completionScripts) != len(completionShells) {
+		t.Errorf("Expected a script for each of %v", completionShells)
+	}
+	app, _ := setupApp([]string{"completion", "tcsh"})
+	if err := app.Run([]string{"ahoy", "completion", "tcsh"}); err == nil || !strings.Contains(err.Error(), "bash|zsh|fish|powershell") {
+		t.Errorf("Expected an error listing the shells, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// assumeYes is set using --yes, to run commands without asking for
+// confirmation.
+var assumeYes bool
+
+// confirmWait is how long ahoy waits before running a command confirmed using
+// AHOY_CONFIRM_RESPONSE, so there's still time to cancel it.
+var confirmWait = 3 * time.Second
+
+// Confirm asks before running a command. It can be written as true, as the
+// message to show, or as a map:
+//
+//	confirm:
+//	  message: This will drop the database with $1. Continue?
+//	  default: no
+//	  type_name: true
+type Confirm struct {
+	// Message is the question asked, and can use the command's arguments and
+	// environment variables, such as $1.
+	Message string
+	// Default is the answer used when only Enter is pressed.
+	Default bool
+	// TypeName makes the user type the command's name to run it, for commands
+	// that are too dangerous for a quick 'y'.
+	TypeName bool `yaml:"type_name"`
+	// off is set by 'confirm: false', so local overrides can turn it off.
+	off bool
+}
+
+// UnmarshalYAML allows confirm to be written as true, false or the message.
+func (c *Confirm) UnmarshalYAML(unmarshal func(any) error) error {
+	var enabled bool
+	if err := unmarshal(&enabled); err == nil {
+		*c = Confirm{off: !enabled}
+		return nil
+	}
+	var message string
+	if err := unmarshal(&message); err == nil {
+		*c = Confirm{Message: message}
+		return nil
+	}
+	type plain Confirm
+	return unmarshal((*plain)(c))
+}
+
+func (c *Confirm) enabled() bool {
+	return c != nil && !c.off
+}
+
+// confirmMessage returns the question to ask before running a command, with
+// its arguments and environment variables expanded.
+func confirmMessage(name string, confirm *Confirm, args []string, envVars []string) string {
+	if confirm.Message == "" {
+		return "Are you sure you want to run '" + name + "'?"
+	}
+	return os.Expand(confirm.Message, func(variable string) string {
+		if variable == "@" || variable == "*" {
+			return strings.Join(args, " ")
+		}
+		if n, err := strconv.Atoi(variable); err == nil {
+			if n > 0 && n <= len(args) {
+				return args[n-1]
+			}
+			return ""
+		}
+		for i := len(envVars) - 1; i >= 0; i-- {
+			if value, found := strings.CutPrefix(envVars[i], variable+"="); found {
+				return value
+			}
+		}
+		return os.Getenv(variable)
+	})
+}
+
+func isAffirmative(answer string) bool {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes", "true", "1":
+		return true
+	}
+	return false
+}
+
+// confirmCommand asks whether a command should run, returning false if the
+// answer was no. The answer can also be given using --yes, or in
+// AHOY_CONFIRM_RESPONSE, which must be the command's name when TypeName is
+// set. It still waits a few seconds so it can be cancelled unless
+// AHOY_CONFIRM_WAIT_SKIP=1 is set. Without either, ahoy can
+// only ask when it's running in a terminal.
+func confirmCommand(name string, message string, confirm *Confirm, in io.Reader, out io.Writer, interactive bool) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+	prompt := ">> " + message + " [y/N] "
+	if confirm.TypeName {
+		prompt = ">> " + message + " Type '" + name + "' to continue: "
+	} else if confirm.Default {
+		prompt = ">> " + message + " [Y/n] "
+	}
+
+	if response := os.Getenv("AHOY_CONFIRM_RESPONSE"); response != "" {
+		fmt.Fprintln(out, prompt+response)
+		if confirm.TypeName && response != name || !confirm.TypeName && !isAffirmative(response) {
+			return false, nil
+		}
+		if os.Getenv("AHOY_CONFIRM_WAIT_SKIP") == "1" {
+			return true, nil
+		}
+		fmt.Fprintln(out, "Waiting for "+confirmWait.String()+"... Press Ctrl+C to cancel.")
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, forwardedSignals...)
+		defer signal.Stop(signals)
+		select {
+		case <-time.After(confirmWait):
+			return true, nil
+		case sig := <-signals:
+			return false, &exitStatus{code: 128 + int(sig.(syscall.Signal))}
+		}
+	}
+
+	if !interactive {
+		return false, errors.New("Command '" + name + "' needs to be confirmed, but ahoy can't ask as it isn't running in a terminal. Use --yes or set AHOY_CONFIRM_RESPONSE=y to run it.")
+	}
+	fmt.Fprint(out, prompt)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		// Input was closed without an answer.
+		fmt.Fprintln(out)
+		return false, nil
+	}
+	answer = strings.TrimSpace(answer)
+	if confirm.TypeName {
+		return answer == name, nil
+	}
+	if answer == "" {
+		return confirm.Default, nil
+	}
+	return isAffirmative(answer), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfirmUnmarshal(t *testing.T) {
+	var config Config
+	data := `
+ahoyapi: v2
+commands:
+  down:
+    cmd: docker compose down
+    confirm: true
+  reset:
+    cmd: ./reset.sh
+    confirm: This will drop $1. Continue?
+  nuke:
+    cmd: ./nuke.sh
+    confirm:
+      message: This deletes everything.
+      default: yes
+      type_name: true
+  quiet:
+    cmd: ./quiet.sh
+    confirm: false
+`
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	if confirm := config.Commands["down"].Confirm; !confirm.enabled() || confirm.Message != "" {
+		t.Errorf("Expected true to ask with the default message, got %+v", confirm)
+	}
+	if confirm := config.Commands["reset"].Confirm; !confirm.enabled() || confirm.Message != "This will drop $1. Continue?" {
+		t.Errorf("Expected a string to set the message, got %+v", confirm)
+	}
+	if confirm := config.Commands["nuke"].Confirm; !confirm.enabled() || !confirm.Default || !confirm.TypeName {
+		t.Errorf("Expected the confirm map to be read, got %+v", confirm)
+	}
+	if confirm := config.Commands["quiet"].Confirm; confirm.enabled() {
+		t.Error("Expected false to turn confirmation off")
+	}
+	if confirm := config.Commands["missing"].Confirm; confirm.enabled() {
+		t.Error("Expected commands without confirm not to ask")
+	}
+}
+
+func TestConfirmMessage(t *testing.T) {
+	t.Setenv("AHOY_TEST_SITE", "example.com")
+	confirm := &Confirm{Message: "Import $1 into $DB_NAME on $AHOY_TEST_SITE? ($@)"}
+	message := confirmMessage("db:import", confirm, []string{"dump.sql", "-v"}, []string{"DB_NAME=drupal"})
+	if expected := "Import dump.sql into drupal on example.com? (dump.sql -v)"; message != expected {
+		t.Errorf("Expected %q, got %q", expected, message)
+	}
+	if message := confirmMessage("down", &Confirm{}, nil, nil); message != "Are you sure you want to run 'down'?" {
+		t.Errorf("Expected the default message, got %q", message)
+	}
+}
+
+func TestConfirmCommand(t *testing.T) {
+	defer func(yes bool) { assumeYes = yes }(assumeYes)
+	t.Setenv("AHOY_CONFIRM_RESPONSE", "")
+	tests := []struct {
+		name      string
+		confirm   Confirm
+		input     string
+		confirmed bool
+	}{
+		{"yes", Confirm{}, "y\n", true},
+		{"yes in capitals", Confirm{}, "YES\n", true},
+		{"no", Confirm{}, "n\n", false},
+		{"enter defaults to no", Confirm{}, "\n", false},
+		{"enter with a default of yes", Confirm{Default: true}, "\n", true},
+		{"closed input", Confirm{Default: true}, "", false},
+		{"typed name", Confirm{TypeName: true}, "db:reset\n", true},
+		{"y isn't the name", Confirm{TypeName: true}, "y\n", false},
+	}
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		confirmed, err := confirmCommand("db:reset", "Reset?", &test.confirm, strings.NewReader(test.input), out, true)
+		if err != nil || confirmed != test.confirmed {
+			t.Errorf("%s: expected %v, got %v: %v", test.name, test.confirmed, confirmed, err)
+		}
+		if !strings.HasPrefix(out.String(), ">> Reset?") {
+			t.Errorf("%s: expected the message to be shown, got %q", test.name, out.String())
+		}
+	}
+
+	if _, err := confirmCommand("db:reset", "Reset?", &Confirm{}, strings.NewReader("y\n"), &bytes.Buffer{}, false); err == nil {
+		t.Error("Expected an error when ahoy can't ask")
+	}
+
+	t.Setenv("AHOY_CONFIRM_RESPONSE", "y")
+	t.Setenv("AHOY_CONFIRM_WAIT_SKIP", "1")
+	if confirmed, err := confirmCommand("db:reset", "Reset?", &Confirm{}, strings.NewReader(""), &bytes.Buffer{}, false); err != nil || !confirmed {
+		t.Errorf("Expected AHOY_CONFIRM_RESPONSE to answer, got %v: %v", confirmed, err)
+	}
+	if confirmed, err := confirmCommand("db:reset", "Reset?", &Confirm{TypeName: true}, strings.NewReader(""), &bytes.Buffer{}, false); err != nil || confirmed {
+		t.Errorf("Expected AHOY_CONFIRM_RESPONSE=y not to confirm a command that needs its name typed, got %v: %v", confirmed, err)
+	}
+	t.Setenv("AHOY_CONFIRM_RESPONSE", "db:reset")
+	if confirmed, err := confirmCommand("db:reset", "Reset?", &Confirm{TypeName: true}, strings.NewReader(""), &bytes.Buffer{}, false); err != nil || !confirmed {
+		t.Errorf("Expected AHOY_CONFIRM_RESPONSE to answer with the command's name, got %v: %v", confirmed, err)
+	}
+	t.Setenv("AHOY_CONFIRM_RESPONSE", "no")
+	if confirmed, _ := confirmCommand("db:reset", "Reset?", &Confirm{}, strings.NewReader(""), &bytes.Buffer{}, false); confirmed {
+		t.Error("Expected AHOY_CONFIRM_RESPONSE=no not to confirm")
+	}
+
+	t.Setenv("AHOY_CONFIRM_RESPONSE", "")
+	assumeYes = true
+	if confirmed, err := confirmCommand("db:reset", "Reset?", &Confirm{TypeName: true}, strings.NewReader(""), &bytes.Buffer{}, false); err != nil || !confirmed {
+		t.Errorf("Expected --yes to confirm, got %v: %v", confirmed, err)
+	}
+}
@@ -71,7 +71,7 @@ func TestDescriptionInCLICommands(t *testing.T) {
 		t.Fatalf("Failed to load test config: %v", err)
 	}
 
-	commands := getCommands(config)
+	commands, _ := getCommands(config)
 
 	// Create a map for easy lookup
 	cmdMap := make(map[string]cli.Command)
@@ -173,7 +173,7 @@ func TestDescriptionWithExistingCommands(t *testing.T) {
 			}
 
 			// Verify CLI command assignment
-			commands := getCommands(config)
+			commands, _ := getCommands(config)
 			var cliCmd *cli.Command
 			for _, c := range commands {
 				if c.Name == test.command {
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// diffLine is a line of a diff, prefixed with ' ', '-' or '+'.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// diffLines compares two lists of lines using their longest common subsequence.
+func diffLines(a []string, b []string) []diffLine {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := []diffLine{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return lines
+}
+
+// unifiedDiff returns the changes between two versions of a file in unified
+// diff format, or an empty string if they are the same.
+func unifiedDiff(name string, before string, after string) string {
+	lines := diffLines(strings.Split(before, "\n"), strings.Split(after, "\n"))
+	changes := []int{}
+	for i, line := range lines {
+		if line.op != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+	for i := 0; i < len(changes); {
+		// Changes close enough to share their context go in the same hunk.
+		last := i
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*diffContext {
+			last++
+		}
+		hunkStart := max(changes[i]-diffContext, 0)
+		hunkEnd := min(changes[last]+diffContext+1, len(lines))
+
+		oldStart, newStart := lineNumbers(lines[:hunkStart])
+		oldCount, newCount := lineNumbers(lines[hunkStart:hunkEnd])
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", oldStart+1, oldCount, newStart+1, newCount)
+		for _, line := range lines[hunkStart:hunkEnd] {
+			out.WriteByte(line.op)
+			out.WriteString(line.text)
+			out.WriteByte('\n')
+		}
+		i = last + 1
+	}
+	return out.String()
+}
+
+// lineNumbers counts the lines of the old and new file in part of a diff.
+func lineNumbers(lines []diffLine) (int, int) {
+	oldLines, newLines := 0, 0
+	for _, line := range lines {
+		if line.op != '+' {
+			oldLines++
+		}
+		if line.op != '-' {
+			newLines++
+		}
+	}
+	return oldLines, newLines
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ahoyConfigDir returns the user's ahoy config directory, following the XDG
+// base directory spec on every platform.
+func ahoyConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "ahoy"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "ahoy"), nil
+}
+
+// ahoyCacheDir returns the directory used to cache downloaded files.
+// It can be overridden with the AHOY_CACHE_DIR environment variable.
+func ahoyCacheDir() (string, error) {
+	if dir := os.Getenv("AHOY_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ahoy"), nil
+}
+
+// ahoyStateDir returns the directory used to keep state between runs, such as
+// the list of trusted files, following the XDG base directory spec.
+func ahoyStateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "ahoy"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "ahoy"), nil
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a pipe,
+// a file or the null device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	if devNull, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, devNull) {
+		return false
+	}
+	return true
+}
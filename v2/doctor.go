@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// doctorReport prints the results of the checks made by 'ahoy doctor'.
+type doctorReport struct {
+	out      io.Writer
+	failures int
+	warnings int
+}
+
+func (r *doctorReport) section(title string, lines int) {
+	fmt.Fprintln(r.out, title+":")
+	if lines == 0 {
+		fmt.Fprintln(r.out, "  nothing to check")
+	}
+}
+
+func (r *doctorReport) pass(text string) {
+	fmt.Fprintln(r.out, "  [ok]   "+text)
+}
+
+func (r *doctorReport) warn(text string) {
+	r.warnings++
+	fmt.Fprintln(r.out, "  [warn] "+text)
+}
+
+func (r *doctorReport) fail(text string) {
+	r.failures++
+	fmt.Fprintln(r.out, "  [fail] "+text)
+}
+
+func (r *doctorReport) add(result doctorResult) {
+	switch result.status {
+	case "fail":
+		r.fail(result.text)
+	case "warn":
+		r.warn(result.text)
+	default:
+		r.pass(result.text)
+	}
+}
+
+// doctorResult is the result of a check, reported once all the files have
+// been walked.
+type doctorResult struct {
+	status string
+	text   string
+}
+
+// doctorCheck collects what the ahoy files need while walking them, so that
+// requirements and env files used in several places are only checked once.
+type doctorCheck struct {
+	requirements map[string]Requirement
+	requiredBy   map[string][]string
+	envFiles     []string
+	envUsers     map[string][]string
+	imports      []doctorResult
+	visited      map[string]bool
+}
+
+// walk records the requirements and env files of an ahoy file, and checks
+// its imports, following them into the imported files.
+func (d *doctorCheck) walk(file string, config Config) {
+	srcDir := AhoyConf.srcDir
+	defer func() { AhoyConf.srcDir = srcDir }()
+	AhoyConf.srcDir = filepath.Dir(file)
+
+	d.require(config.Requires, file)
+	d.env(config.Env, file)
+
+	names := []string{}
+	for name := range config.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := config.Commands[name]
+		user := "'" + name + "' in " + file
+		d.require(cmd.Requires, user)
+		d.env(cmd.Env, user)
+
+		found := 0
+		missing := []string{}
+		for _, include := range cmd.Imports {
+			if include == "" {
+				continue
+			}
+			path, err := resolveImport(include)
+			if err != nil {
+				d.importResult("fail", include+" ("+user+"): "+err.Error())
+				continue
+			}
+			if path == "" {
+				missing = append(missing, include)
+				continue
+			}
+			found++
+			if err := verifyImportSignature(path, include); err != nil {
+				d.importResult("fail", include+" ("+user+"): "+err.Error())
+				continue
+			}
+			imported, err := getConfig(path)
+			if err != nil {
+				d.importResult("fail", include+" ("+user+"): "+err.Error())
+				continue
+			}
+			d.importResult("pass", include+" ("+user+")")
+			if !d.visited[path] {
+				d.visited[path] = true
+				d.walk(path, imported)
+			}
+		}
+		// Missing imports are allowed, so private commands can be left out,
+		// as long as the command still has some subcommands.
+		for _, include := range missing {
+			if found == 0 && !cmd.Optional {
+				d.importResult("fail", include+" ("+user+") was not found, and no other imports were either")
+			} else {
+				d.importResult("warn", include+" ("+user+") was not found")
+			}
+		}
+	}
+}
+
+func (d *doctorCheck) importResult(status string, text string) {
+	d.imports = append(d.imports, doctorResult{status, text})
+}
+
+func (d *doctorCheck) require(requirements []Requirement, user string) {
+	for _, r := range requirements {
+		key := r.String() + r.Probe
+		if _, exists := d.requirements[key]; !exists {
+			d.requirements[key] = r
+		}
+		d.requiredBy[key] = append(d.requiredBy[key], user)
+	}
+}
+
+func (d *doctorCheck) env(envFiles []string, user string) {
+	for _, envFile := range envFiles {
+		path := filepath.Join(AhoyConf.srcDir, envFile)
+		if _, exists := d.envUsers[path]; !exists {
+			d.envFiles = append(d.envFiles, path)
+		}
+		d.envUsers[path] = append(d.envUsers[path], user)
+	}
+}
+
+// runDoctor checks everything the loaded ahoy files need, returning whether
+// all the checks passed. Warnings don't fail the checks.
+func runDoctor(out io.Writer) bool {
+	report := &doctorReport{out: out}
+
+	// Requirement probes come from the ahoy files, so they are only run when
+	// the files are trusted.
+	untrusted, reasons := []string{}, map[string]string{}
+	trustAll := os.Getenv("AHOY_TRUST_ALL") != ""
+	if !trustAll {
+		untrusted, reasons = untrustedFiles(loadTrustStore())
+	}
+	report.section("Trust", len(AhoyConf.configFiles))
+	for _, file := range AhoyConf.configFiles {
+		if reason, found := reasons[file]; found {
+			report.fail(file + " is not trusted (" + reason + "), run 'ahoy trust' after checking it")
+		} else if trustAll {
+			report.pass(file + " (AHOY_TRUST_ALL is set)")
+		} else {
+			report.pass(file)
+		}
+	}
+	probe := len(untrusted) == 0
+
+	d := &doctorCheck{
+		requirements: map[string]Requirement{},
+		requiredBy:   map[string][]string{},
+		envUsers:     map[string][]string{},
+		visited:      map[string]bool{},
+	}
+	for _, level := range AhoyConf.configLevels {
+		d.walk(level.file, level.config)
+	}
+
+	report.section("Imports", len(d.imports))
+	for _, result := range d.imports {
+		report.add(result)
+	}
+
+	keys := []string{}
+	for key := range d.requirements {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	report.section("Requirements", len(keys))
+	for _, key := range keys {
+		r := d.requirements[key]
+		users := " (required by " + strings.Join(d.requiredBy[key], ", ") + ")"
+		found, err := checkRequirement(r, probe)
+		switch {
+		case err != nil:
+			report.fail(r.String() + ": " + err.Error() + users)
+		case !probe && (r.Version != "" || r.Probe != ""):
+			report.warn(r.String() + ": found " + found + ", but the version isn't checked until the ahoy files are trusted" + users)
+		default:
+			report.pass(r.String() + ": " + found + users)
+		}
+	}
+
+	report.section("Env files", len(d.envFiles))
+	for _, path := range d.envFiles {
+		users := " (used by " + strings.Join(d.envUsers[path], ", ") + ")"
+		if fileExists(path) {
+			report.pass(path + users)
+		} else {
+			report.warn(path + " is missing" + users)
+		}
+	}
+
+	if report.failures > 0 {
+		fmt.Fprintf(out, "%d checks failed, %d warnings.\n", report.failures, report.warnings)
+		return false
+	}
+	fmt.Fprintf(out, "All checks passed, %d warnings.\n", report.warnings)
+	return true
+}
+
+func doctorAction(c *cli.Context) error {
+	if len(AhoyConf.configLevels) == 0 {
+		return errors.New("No .ahoy.yml found to check. You can create an example one by using 'ahoy init'.")
+	}
+	if !runDoctor(os.Stdout) {
+		// The report already explains what failed.
+		return &exitStatus{code: exitFailure}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDoctor(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	file := writeFile(".ahoy.yml", "ahoyapi: v2\nenv: .env\nrequires: [bash]\ncommands:\n  shared:\n    imports: [shared.ahoy.yml, private.ahoy.yml]\n")
+	writeFile("shared.ahoy.yml", "ahoyapi: v2\ncommands:\n  deploy:\n    cmd: echo deploy\n    requires: [ahoy-missing-tool]\n")
+	config, err := getConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(levels []configLevel) { AhoyConf.configLevels = levels }(AhoyConf.configLevels)
+	AhoyConf.configLevels = []configLevel{{file: file, config: config}}
+	output := &bytes.Buffer{}
+	if runDoctor(output) {
+		t.Error("Expected the missing tool to fail the checks")
+	}
+	for _, expected := range []string{
+		"[ok]   shared.ahoy.yml ('shared' in " + file + ")",
+		"[warn] private.ahoy.yml ('shared' in " + file + ") was not found",
+		"[ok]   bash:",
+		"[fail] ahoy-missing-tool: ahoy-missing-tool was not found in your PATH (required by 'deploy' in " + filepath.Join(dir, "shared.ahoy.yml") + ")",
+		"[warn] " + filepath.Join(dir, ".env") + " is missing",
+		"1 checks failed, 2 warnings.",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", expected, output.String())
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// Exit codes for ahoy's own failures. When a command fails, ahoy exits with
+// the command's exit code instead, or 128 plus the signal number if the
+// command was killed by a signal, like a shell does.
+const (
+	exitFailure = 1
+	// exitMissingRequirement is EX_UNAVAILABLE from sysexits.h.
+	exitMissingRequirement = 69
+	// exitLocked is EX_TEMPFAIL, for commands whose lock is held when
+	// --no-wait is set.
+	exitLocked = 75
+	// exitUntrusted is EX_NOPERM, for untrusted files and failed signatures.
+	exitUntrusted = 77
+	// exitConfigError is EX_CONFIG, for ahoy files that can't be loaded.
+	exitConfigError = 78
+	// exitTimeout matches the code used by the timeout command.
+	exitTimeout = 124
+	// exitCommandNotFound matches the shell's code for unknown commands.
+	exitCommandNotFound = 127
+)
+
+// ahoyError is an error with the code ahoy exits with when it reaches main.
+type ahoyError struct {
+	code int
+	err  error
+}
+
+func (e *ahoyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ahoyError) Unwrap() error {
+	return e.err
+}
+
+func newAhoyError(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ahoyError{code: code, err: err}
+}
+
+// configError marks an error loading an ahoy file.
+func configError(err error) error {
+	return newAhoyError(exitConfigError, err)
+}
+
+// exitStatus makes ahoy exit with a code without printing anything, as when a
+// command fails and has already shown its own output.
+type exitStatus struct {
+	code int
+}
+
+func (e *exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// timeoutError is returned when a command is stopped for running longer than
+// its timeout.
+type timeoutError struct {
+	elapsed time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return "timed out after " + e.elapsed.Round(time.Millisecond).String()
+}
+
+// interruptedError is returned when a command exits after ahoy passed it a
+// signal, so it isn't retried.
+type interruptedError struct {
+	signal os.Signal
+	err    error
+}
+
+func (e *interruptedError) Error() string {
+	return "interrupted by " + e.signal.String() + ": " + e.err.Error()
+}
+
+func (e *interruptedError) Unwrap() error {
+	return e.err
+}
+
+// commandStatus returns the status ahoy exits with when a command fails,
+// explaining when it was stopped for taking too long.
+func commandStatus(name string, err error) error {
+	var timeoutErr *timeoutError
+	if errors.As(err, &timeoutErr) {
+		return newAhoyError(exitTimeout, errors.New("Command '"+name+"' "+timeoutErr.Error()+"."))
+	}
+	return commandExitStatus(err)
+}
+
+// errStopCommands stops any command running once --help or --version have
+// been handled.
+var errStopCommands = errors.New("don't continue with commands")
+
+// commandExitStatus returns the exit status of a command that failed to run,
+// keeping the code it exited with.
+func commandExitStatus(err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		// The command couldn't be started at all.
+		return newAhoyError(exitCommandNotFound, err)
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return &exitStatus{code: 128 + int(status.Signal())}
+	}
+	return &exitStatus{code: exitErr.ExitCode()}
+}
+
+// exitCode returns the code ahoy exits with for an error, and whether the
+// error should be printed.
+func exitCode(err error) (int, bool) {
+	var status *exitStatus
+	var ahoyErr *ahoyError
+	switch {
+	case err == nil, errors.Is(err, errStopCommands):
+		return 0, false
+	case errors.As(err, &status):
+		return status.code, false
+	case errors.As(err, &ahoyErr):
+		return ahoyErr.code, true
+	}
+	return exitFailure, true
+}
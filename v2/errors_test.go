@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestCommandExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses bash to exit with a code")
+	}
+
+	err := commandExitStatus(exec.Command("bash", "-c", "exit 3").Run())
+	if code, show := exitCode(err); code != 3 || show {
+		t.Errorf("Expected the command's exit code 3 without a message, got %d (show: %v)", code, show)
+	}
+
+	// Commands killed by a signal exit with 128 plus the signal, as in a shell.
+	err = commandExitStatus(exec.Command("bash", "-c", "kill -INT $$").Run())
+	if code, _ := exitCode(err); code != 130 {
+		t.Errorf("Expected exit code 130 for SIGINT, got %d", code)
+	}
+
+	err = commandExitStatus(exec.Command("ahoy-missing-entrypoint").Run())
+	if code, show := exitCode(err); code != exitCommandNotFound || !show {
+		t.Errorf("Expected a missing entrypoint to exit with %d, got %d", exitCommandNotFound, code)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+		show bool
+	}{
+		{nil, 0, false},
+		{errStopCommands, 0, false},
+		{errors.New("something failed"), exitFailure, true},
+		{configError(errors.New("bad yaml")), exitConfigError, true},
+		{newAhoyError(exitMissingRequirement, errors.New("no docker")), exitMissingRequirement, true},
+		{&exitStatus{code: 2}, 2, false},
+	}
+	for _, test := range tests {
+		code, show := exitCode(test.err)
+		if code != test.code || show != test.show {
+			t.Errorf("Expected %v to exit with %d (show: %v), got %d (show: %v)", test.err, test.code, test.show, code, show)
+		}
+	}
+
+	if configError(nil) != nil {
+		t.Error("Expected wrapping a nil error to return nil")
+	}
+}
+
+func TestSetupAppConfigError(t *testing.T) {
+	_, err := setupApp([]string{"-f", "testdata/missing-cmd.ahoy.yml"})
+	if code, _ := exitCode(err); code != exitConfigError {
+		t.Errorf("Expected a config error, got %d: %v", code, err)
+	}
+}
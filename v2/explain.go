@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// dryRun is set using --dry-run, to show what commands would do instead of
+// running them.
+var dryRun bool
+
+// commandPlan is everything a command would do when it's run, as shown by
+// --dry-run and 'ahoy explain'.
+type commandPlan struct {
+	name string
+	// fullName includes the names of the commands that imported it, such as
+	// "db reset".
+	fullName string
+	cmd      Command
+	// argv is the entrypoint, with its placeholders replaced, and the
+	// command's arguments.
+	argv   []string
+	dir    string
+	srcDir string
+	// envFiles are the env files of the ahoy file and then of the command,
+	// relative to srcDir.
+	envFiles    []string
+	inputValues map[string]string
+	// inputSources says where each of inputValues came from.
+	inputSources map[string]string
+	// interactive is set when ahoy can ask for inputs.
+	interactive bool
+	requires    []Requirement
+	timeout     time.Duration
+	retry       retryPolicy
+	cacheTTL    time.Duration
+	args        []string
+}
+
+// maskValue hides the value of a variable, which could be a secret.
+func maskValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "****"
+}
+
+// indent indents the lines after the first, so multi-line values line up.
+func indent(text string, prefix string) string {
+	return strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\n"+prefix)
+}
+
+// planVariables returns the variables a command would be given from its env
+// files and inputs, in the order they're first set, with where the value
+// that's used comes from. Inputs are labelled with the argument they were
+// given as, the environment, their default, or "prompt" when ahoy will ask.
+func planVariables(p commandPlan) (vars []string, sources map[string]string, values map[string]string) {
+	sources = map[string]string{}
+	values = map[string]string{}
+	set := func(line string, source string) {
+		name, value, _ := strings.Cut(line, "=")
+		if _, found := sources[name]; !found {
+			vars = append(vars, name)
+		}
+		sources[name] = source
+		values[name] = value
+	}
+	for _, envFile := range p.envFiles {
+		for _, line := range getEnvironmentVars(filepath.Join(p.srcDir, envFile)) {
+			set(line, envFile)
+		}
+	}
+	for _, input := range p.cmd.Inputs {
+		name := input.envName()
+		if value, found := p.inputValues[input.Name]; found {
+			set(name+"="+value, p.inputSources[input.Name])
+		} else if _, found := sources[name]; found {
+			// Set by an env file, which is used as it is.
+		} else if value, found := os.LookupEnv(name); found {
+			set(name+"="+value, "environment")
+		} else if !p.interactive && input.Default != "" {
+			set(name+"="+input.Default, "default")
+		} else {
+			set(name+"=", "prompt")
+		}
+	}
+	return vars, sources, values
+}
+
+// printPlan prints what a command would do, with the values of its variables
+// masked.
+func printPlan(out io.Writer, p commandPlan) {
+	fmt.Fprintln(out, "Command:    "+p.name)
+	if p.cmd.defined.file != "" {
+		fmt.Fprintln(out, "Defined in: "+p.cmd.defined.String())
+	}
+	fmt.Fprintln(out, "Directory:  "+p.dir)
+
+	fmt.Fprintln(out, "Runs:")
+	for i, item := range p.argv {
+		fmt.Fprintf(out, "  [%d] %s\n", i, indent(item, "      "))
+	}
+
+	if len(p.envFiles) > 0 {
+		fmt.Fprintln(out, "Env files:")
+		for _, envFile := range p.envFiles {
+			if fileExists(filepath.Join(p.srcDir, envFile)) {
+				fmt.Fprintln(out, "  "+envFile)
+			} else {
+				fmt.Fprintln(out, "  "+envFile+" (not found, skipped)")
+			}
+		}
+	}
+	vars, sources, values := planVariables(p)
+	if len(vars) > 0 {
+		fmt.Fprintln(out, "Variables:")
+		for _, name := range vars {
+			fmt.Fprintf(out, "  %s=%s (%s)\n", name, maskValue(values[name]), sources[name])
+		}
+	}
+
+	fmt.Fprintln(out, "Steps:")
+	steps := planSteps(p, sources, values)
+	for i, step := range steps {
+		fmt.Fprintf(out, "  %d. %s\n", i+1, indent(step, "     "))
+	}
+}
+
+// planSteps describes what happens when a command runs, in order.
+func planSteps(p commandPlan, sources map[string]string, values map[string]string) []string {
+	steps := []string{}
+	if len(p.requires) > 0 {
+		names := []string{}
+		for _, requirement := range p.requires {
+			names = append(names, requirement.String())
+		}
+		steps = append(steps, "Check it has the tools it requires: "+strings.Join(names, ", ")+".")
+	}
+
+	envVars := []string{}
+	for name, value := range values {
+		envVars = append(envVars, name+"="+value)
+	}
+	for _, input := range p.cmd.Inputs {
+		if sources[input.envName()] == "environment" {
+			steps = append(steps, "Use $"+input.envName()+" from the environment for "+input.Name+".")
+			continue
+		}
+		if sources[input.envName()] != "prompt" {
+			continue
+		}
+		step := "Ask for " + input.Name
+		if input.Type != "" && input.Type != "text" {
+			step += " (" + input.Type + ")"
+		}
+		if input.Default != "" {
+			step += ", defaulting to " + input.Default
+		}
+		steps = append(steps, step+", into $"+input.envName()+".")
+	}
+
+	if p.cmd.Cache != nil {
+		steps = append(steps, "Replay its output instead, without asking to confirm, if it was cached in the last "+p.cacheTTL.String()+".")
+	}
+	if p.cmd.Confirm.enabled() {
+		steps = append(steps, "Ask: "+confirmMessage(p.name, p.cmd.Confirm, p.args, envVars))
+	}
+	if len(p.cmd.Sources) > 0 {
+		step := "Skip it if its sources haven't changed since it last succeeded."
+		if fingerprint, err := sourcesFingerprint(p.srcDir, p.cmd, p.args); err == nil {
+			if upToDate, _ := isUpToDate(p.srcDir, p.fullName, fingerprint, p.cmd.Generates); upToDate {
+				step += " It's up to date now."
+			} else {
+				step += " It would run now."
+			}
+		}
+		steps = append(steps, step)
+	}
+	if lock := lockName(p.cmd.Lock, p.fullName); lock != "" {
+		steps = append(steps, "Wait for the lock '"+lock+"'.")
+	}
+
+	step := "Run it"
+	if p.timeout > 0 {
+		step += ", stopping it after " + p.timeout.String()
+	}
+	if p.retry.attempts > 1 {
+		step += ", trying up to " + strconv.Itoa(p.retry.attempts) + " times"
+	}
+	steps = append(steps, step+".")
+	if p.cmd.Cleanup != "" {
+		steps = append(steps, "If it fails, run the cleanup:\n"+p.cmd.Cleanup)
+	}
+	return steps
+}
+
+// refuseDryRun wraps the action of a built in command that makes changes,
+// such as 'cache clear', so it fails with --dry-run instead of making them.
+func refuseDryRun(action func(c *cli.Context) error) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if dryRun {
+			return errors.New("'" + c.Command.HelpName + "' can't be run with --dry-run, as it would still make changes.")
+		}
+		return action(c)
+	}
+}
+
+func explainAction(c *cli.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return errors.New("Usage: ahoy explain <command> [args...]")
+	}
+	command, commandArgs := findCommand(c.App.Commands, args)
+	if command == nil {
+		return newAhoyError(exitCommandNotFound, errors.New("Command not found for '"+args[0]+"'"))
+	}
+	if len(command.Subcommands) > 0 {
+		fmt.Println("Command:    " + command.Name)
+		fmt.Println("Subcommands:")
+		for _, subcommand := range command.Subcommands {
+			fmt.Println("  " + strings.Join(subcommand.Names(), ", "))
+		}
+		return nil
+	}
+	if command.Action == nil || !command.SkipFlagParsing {
+		return errors.New("'" + command.Name + "' is built into ahoy, only commands with a 'cmd' in an ahoy file can be explained.")
+	}
+
+	defer func(enabled bool) { dryRun = enabled }(dryRun)
+	dryRun = true
+	return invokeCommand(c, command, commandArgs)
+}
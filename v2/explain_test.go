@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintPlan(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("DB_NAME=drupal\nDB_PASS=secret\n"), 0644)
+	os.WriteFile(filepath.Join(dir, ".env.local"), []byte("DB_NAME=local\n"), 0644)
+	cmd := Command{
+		Cmd:     "echo one\necho two",
+		Cleanup: "echo cleanup",
+		Confirm: &Confirm{Message: "Import $1 into $DB_NAME?"},
+		Lock:    "db",
+		Inputs:  []Input{{Name: "site"}, {Name: "environment", Type: "select", Choices: []string{"dev"}, Default: "dev"}},
+		defined: definition{file: filepath.Join(dir, ".ahoy.yml"), line: 3},
+	}
+	retry, _ := newRetryPolicy(&Retry{Attempts: 3})
+	out := &bytes.Buffer{}
+	printPlan(out, commandPlan{
+		name:         "db:import",
+		fullName:     "db:import",
+		cmd:          cmd,
+		argv:         entrypointCommand([]string{"bash", "-c", "{{cmd}}", "{{name}}"}, cmd.Cmd, "db:import", []string{"dump.sql"}),
+		dir:          dir,
+		srcDir:       dir,
+		envFiles:     []string{".env", ".env.local", ".env.missing"},
+		inputValues:  map[string]string{"site": "example.com"},
+		inputSources: map[string]string{"site": "--site"},
+		interactive:  true,
+		requires:     []Requirement{{Name: "docker", Version: ">=24"}},
+		retry:        retry,
+		args:         []string{"dump.sql"},
+	})
+
+	for _, expected := range []string{
+		"Defined in: " + filepath.Join(dir, ".ahoy.yml") + ":3\n",
+		"  [2] echo one\n      echo two\n  [3] db:import\n  [4] dump.sql\n",
+		"  .env.missing (not found, skipped)\n",
+		"  DB_NAME=**** (.env.local)\n  DB_PASS=**** (.env)\n  SITE=**** (--site)\n  ENVIRONMENT= (prompt)\n",
+		"  1. Check it has the tools it requires: docker>=24.\n",
+		"  2. Ask for environment (select), defaulting to dev, into $ENVIRONMENT.\n",
+		"  3. Ask: Import dump.sql into local?\n",
+		"  4. Wait for the lock 'db'.\n",
+		"  5. Run it, trying up to 3 times.\n",
+		"  6. If it fails, run the cleanup:\n     echo cleanup\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in the plan, got:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "secret") || strings.Contains(out.String(), "example.com") {
+		t.Errorf("Expected the values of variables to be masked, got:\n%s", out.String())
+	}
+
+	// Without a terminal, inputs that weren't given use their default.
+	t.Setenv("AHOY_TEST_BRANCH", "main")
+	cmd.Inputs = []Input{{Name: "site", Arg: 1}, {Name: "branch", Env: "AHOY_TEST_BRANCH"}, {Name: "environment", Default: "dev"}}
+	out.Reset()
+	printPlan(out, commandPlan{
+		name:         "deploy",
+		fullName:     "deploy",
+		cmd:          cmd,
+		dir:          dir,
+		srcDir:       dir,
+		inputValues:  map[string]string{"site": "example.com"},
+		inputSources: map[string]string{"site": "arg $1"},
+		args:         []string{"example.com"},
+	})
+	for _, expected := range []string{
+		"  SITE=**** (arg $1)\n  AHOY_TEST_BRANCH=**** (environment)\n  ENVIRONMENT=**** (default)\n",
+		"  1. Use $AHOY_TEST_BRANCH from the environment for branch.\n  2. Ask: ",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in the plan, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	defer func(enabled bool) { dryRun = enabled }(dryRun)
+	ran := filepath.Join(t.TempDir(), "ran")
+	file := filepath.Join(t.TempDir(), ".ahoy.yml")
+	os.WriteFile(file, []byte("ahoyapi: v2\ncommands:\n  touch:\n    cmd: touch "+ran+"\n"), 0644)
+
+	out, _ := appRun([]string{"ahoy", "-f", file, "explain", "touch"})
+	if !strings.Contains(out, "Defined in: "+file) || fileExists(ran) {
+		t.Errorf("Expected 'ahoy explain' to show the command without running it, got:\n%s", out)
+	}
+	out, _ = appRun([]string{"ahoy", "-f", file, "--dry-run", "touch"})
+	if !strings.Contains(out, "Command:    touch") || fileExists(ran) {
+		t.Errorf("Expected --dry-run to show the command without running it, got:\n%s", out)
+	}
+}
+
+func TestDryRunRefusesBuiltinChanges(t *testing.T) {
+	defer func(enabled bool) { dryRun = enabled }(dryRun)
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	dir, _ := resultsCacheDir()
+	os.MkdirAll(dir, 0755)
+	cached := filepath.Join(dir, "build.json")
+	os.WriteFile(cached, []byte("{}"), 0644)
+	file := filepath.Join(t.TempDir(), ".ahoy.yml")
+	os.WriteFile(file, []byte("ahoyapi: v2\ncommands:\n  build:\n    cmd: make\n"), 0644)
+
+	args := []string{"-f", file, "--dry-run", "cache", "clear"}
+	app, _ := setupApp(args)
+	err := app.Run(append([]string{"ahoy"}, args...))
+	if err == nil || !strings.Contains(err.Error(), "'ahoy cache clear' can't be run with --dry-run") {
+		t.Errorf("Expected --dry-run to be refused for 'cache clear', got %v", err)
+	}
+	if !fileExists(cached) {
+		t.Error("Expected --dry-run not to clear the cache")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ahoyStateDirName is the directory next to an ahoy file where ahoy keeps
+// state for the project, such as the fingerprints of command sources.
+const ahoyStateDirName = ".ahoy"
+
+// force is set using --force or AHOY_FORCE, to run commands even when their
+// sources haven't changed or their output is cached.
+var force bool
+
+// sourcesFingerprint hashes a command, its arguments and the content of its
+// sources, so any change to them means the command needs to run again.
+func sourcesFingerprint(dir string, cmd Command, args []string) (string, error) {
+	files, err := globFiles(dir, cmd.Sources)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%q %q\n", cmd.Cmd, args)
+	for _, file := range files {
+		sum, err := fileSHA256(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s %s\n", sum, file)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// unmatchedSources returns the sources patterns that don't match any files,
+// which are usually mistakes, and whether any files matched at all.
+func unmatchedSources(dir string, sources []string) ([]string, bool, error) {
+	unmatched := []string{}
+	matched := false
+	for _, pattern := range sources {
+		if pattern = strings.TrimSpace(pattern); pattern == "" || strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		files, err := globFiles(dir, []string{pattern})
+		if err != nil {
+			return nil, false, err
+		}
+		if len(files) == 0 {
+			unmatched = append(unmatched, pattern)
+		} else {
+			matched = true
+		}
+	}
+	return unmatched, matched, nil
+}
+
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// stateFileName turns a command's full name, such as "db reset", into a path
+// in the state directory, with a directory for each command that imported it.
+func stateFileName(name string) string {
+	parts := strings.Fields(name)
+	for i, part := range parts {
+		parts[i] = unsafeFileChars.ReplaceAllString(part, "_")
+		if parts[i] == "." || parts[i] == ".." {
+			parts[i] = "_"
+		}
+	}
+	return filepath.Join(parts...)
+}
+
+// fingerprintPath returns where the fingerprint of a command's last
+// successful run is kept.
+func fingerprintPath(dir string, name string) string {
+	return filepath.Join(dir, ahoyStateDirName, "fingerprints", stateFileName(name))
+}
+
+// isUpToDate reports whether a command last ran successfully with the same
+// fingerprint, and everything it generates still exists.
+func isUpToDate(dir string, name string, fingerprint string, generates []string) (bool, error) {
+	data, err := os.ReadFile(fingerprintPath(dir, name))
+	if err != nil || strings.TrimSpace(string(data)) != fingerprint {
+		return false, nil
+	}
+	for _, pattern := range generates {
+		if strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		files, err := globFiles(dir, []string{pattern})
+		if err != nil {
+			return false, err
+		}
+		if len(files) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ensureStateDir creates the state directory next to an ahoy file, with a
+// .gitignore so it's never committed by mistake.
+func ensureStateDir(dir string) error {
+	stateDir := filepath.Join(dir, ahoyStateDirName)
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+	ignore := filepath.Join(stateDir, ".gitignore")
+	if fileExists(ignore) {
+		return nil
+	}
+	return os.WriteFile(ignore, []byte("*\n"), 0644)
+}
+
+// saveFingerprint records a successful run of a command.
+func saveFingerprint(dir string, name string, fingerprint string) error {
+	if err := ensureStateDir(dir); err != nil {
+		return err
+	}
+	file := fingerprintPath(dir, name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, []byte(fingerprint+"\n"), 0644)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+func TestSourcesFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("one"), 0644)
+	cmd := Command{Cmd: "build", Sources: []string{"*.js"}}
+
+	first, err := sourcesFingerprint(dir, cmd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same, _ := sourcesFingerprint(dir, cmd, nil); same != first {
+		t.Error("Expected the fingerprint to be the same when nothing changed")
+	}
+	if withArgs, _ := sourcesFingerprint(dir, cmd, []string{"prod"}); withArgs == first {
+		t.Error("Expected the arguments to change the fingerprint")
+	}
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("two"), 0644)
+	if changed, _ := sourcesFingerprint(dir, cmd, nil); changed == first {
+		t.Error("Expected a changed source to change the fingerprint")
+	}
+}
+
+func TestIsUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	if upToDate, _ := isUpToDate(dir, "build", "abc", nil); upToDate {
+		t.Error("Expected a command that never ran not to be up to date")
+	}
+	if err := saveFingerprint(dir, "build", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if !fileExists(filepath.Join(dir, ".ahoy", ".gitignore")) {
+		t.Error("Expected the state directory to be ignored by git")
+	}
+	if upToDate, _ := isUpToDate(dir, "build", "abc", nil); !upToDate {
+		t.Error("Expected the command to be up to date")
+	}
+	if upToDate, _ := isUpToDate(dir, "build", "def", nil); upToDate {
+		t.Error("Expected a different fingerprint not to be up to date")
+	}
+	if upToDate, _ := isUpToDate(dir, "build", "abc", []string{"dist/*.js"}); upToDate {
+		t.Error("Expected missing generated files not to be up to date")
+	}
+}
+
+func TestSourcesSkipCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses bash to count runs")
+	}
+	defer func(dir string) { AhoyConf.srcDir = dir }(AhoyConf.srcDir)
+	defer func(f bool) { force = f }(force)
+	dir := t.TempDir()
+	AhoyConf.srcDir = dir
+	os.WriteFile(filepath.Join(dir, "input.txt"), []byte("one"), 0644)
+
+	config := Config{Entrypoint: []string{"bash", "-c", "{{cmd}}", "{{name}}"}, Commands: map[string]Command{
+		"build": {Cmd: `echo run >> "` + filepath.Join(dir, "runs") + `"`, Sources: []string{"*.txt"}},
+	}}
+	commands, err := getCommands(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := cli.NewApp()
+	app.Commands = commands
+	runs := func() int {
+		app.Run([]string{"ahoy", "build"})
+		data, _ := os.ReadFile(filepath.Join(dir, "runs"))
+		return strings.Count(string(data), "run")
+	}
+
+	if count := runs(); count != 1 {
+		t.Errorf("Expected the command to run the first time, got %d runs", count)
+	}
+	if count := runs(); count != 1 {
+		t.Errorf("Expected the command to be skipped when nothing changed, got %d runs", count)
+	}
+	os.WriteFile(filepath.Join(dir, "input.txt"), []byte("two"), 0644)
+	if count := runs(); count != 2 {
+		t.Errorf("Expected the command to run when a source changed, got %d runs", count)
+	}
+	force = true
+	if count := runs(); count != 3 {
+		t.Errorf("Expected --force to run the command anyway, got %d runs", count)
+	}
+}
+
+func TestUnmatchedSources(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("one"), 0644)
+
+	unmatched, matched, err := unmatchedSources(dir, []string{"*.js", "src/**/*.ts", "!*.min.js"})
+	if err != nil || !matched || strings.Join(unmatched, ",") != "src/**/*.ts" {
+		t.Errorf("Expected only src/**/*.ts to be unmatched, got %q, %v: %v", unmatched, matched, err)
+	}
+	if _, matched, _ := unmatchedSources(dir, []string{"*.ts"}); matched {
+		t.Error("Expected no sources to match")
+	}
+}
+
+func TestSameNamedSubcommandsKeepSeparateState(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses bash to count runs")
+	}
+	defer func(dir string) { AhoyConf.srcDir = dir }(AhoyConf.srcDir)
+	dir := t.TempDir()
+	AhoyConf.srcDir = dir
+	runs := filepath.Join(dir, "runs")
+	os.WriteFile(filepath.Join(dir, "input.txt"), []byte("one"), 0644)
+	for _, name := range []string{"db", "cache"} {
+		os.WriteFile(filepath.Join(dir, name+".ahoy.yml"), []byte(`ahoyapi: v2
+entrypoint: [bash, -c, "{{cmd}}", "{{name}}"]
+commands:
+  reset:
+    cmd: echo `+name+` >> "`+runs+`"
+    sources: ["*.txt"]
+    lock: true
+  stale:
+    cmd: echo `+name+`-stale >> "`+runs+`"
+    sources: ["*.missing"]
+`), 0644)
+	}
+
+	config := Config{Commands: map[string]Command{
+		"db":    {Imports: []string{"db.ahoy.yml"}},
+		"cache": {Imports: []string{"cache.ahoy.yml"}},
+	}}
+	commands, err := getCommands(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := cli.NewApp()
+	app.Commands = commands
+	count := func(line string) int {
+		data, _ := os.ReadFile(runs)
+		return strings.Count(string(data), line+"\n")
+	}
+
+	app.Run([]string{"ahoy", "db", "reset"})
+	app.Run([]string{"ahoy", "cache", "reset"})
+	app.Run([]string{"ahoy", "db", "reset"})
+	if count("db") != 1 || count("cache") != 1 {
+		t.Errorf("Expected each reset to run once, got %d and %d runs", count("db"), count("cache"))
+	}
+	if !fileExists(fingerprintPath(dir, "db reset")) || !fileExists(fingerprintPath(dir, "cache reset")) {
+		t.Error("Expected each reset to have its own fingerprint")
+	}
+	if lockPath(dir, "db reset") == lockPath(dir, "cache reset") || lockPath(dir, "db reset") == lockPath(dir, "db:reset") {
+		t.Error("Expected each reset to have its own lock")
+	}
+
+	// Sources that don't match any files can't tell whether anything changed.
+	app.Run([]string{"ahoy", "db", "stale"})
+	app.Run([]string{"ahoy", "db", "stale"})
+	if count("db-stale") != 2 {
+		t.Errorf("Expected a command without any matching sources to always run, got %d runs", count("db-stale"))
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"flag"
+	"os"
+	"strings"
 
 	"github.com/urfave/cli"
 )
@@ -13,10 +15,45 @@ var globalFlags = []cli.Flag{
 		EnvVar:      "AHOY_VERBOSE",
 		Destination: &verbose,
 	},
-	cli.StringFlag{
-		Name:        "file, f",
-		Usage:       "Use a specific ahoy file.",
-		Destination: &sourcefile,
+	cli.StringSliceFlag{
+		Name:  "file, f",
+		Usage: "Use a specific ahoy file. Repeat to merge several files, with later files overriding earlier ones. Use '-' to read from stdin. Defaults to the files in $AHOY_FILE, separated by commas.",
+	},
+	cli.DurationFlag{
+		Name:        "grace-period",
+		Usage:       "How long a command has to exit after ahoy passes on a signal such as Ctrl+C, before it's killed.",
+		EnvVar:      "AHOY_GRACE_PERIOD",
+		Value:       defaultGracePeriod,
+		Destination: &gracePeriod,
+	},
+	cli.DurationFlag{
+		Name:        "timeout",
+		Usage:       "Stop the command if it's still running after this long, replacing any timeout set for it.",
+		EnvVar:      "AHOY_TIMEOUT",
+		Destination: &commandTimeout,
+	},
+	cli.BoolFlag{
+		Name:        "force",
+		Usage:       "Run commands even when their sources haven't changed since they last ran, or their output is cached.",
+		EnvVar:      "AHOY_FORCE",
+		Destination: &force,
+	},
+	cli.BoolFlag{
+		Name:        "no-wait",
+		Usage:       "Fail straight away if a command's lock is held by another process, instead of waiting for it.",
+		EnvVar:      "AHOY_NO_WAIT",
+		Destination: &noWait,
+	},
+	cli.BoolFlag{
+		Name:        "yes, y",
+		Usage:       "Run commands without asking for confirmation.",
+		Destination: &assumeYes,
+	},
+	cli.BoolFlag{
+		Name:        "dry-run",
+		Usage:       "Show what commands would run, without running them. Built in commands that make changes refuse to run.",
+		EnvVar:      "AHOY_DRY_RUN",
+		Destination: &dryRun,
 	},
 	cli.BoolFlag{
 		Name:  "help, h",
@@ -40,15 +77,38 @@ func flagSet(name string, flags []cli.Flag) *flag.FlagSet {
 	return set
 }
 
-func initFlags(incomingFlags []string) {
+// initFlags parses the global flags ahead of cli and returns the remaining
+// arguments, which start with the command to run.
+func initFlags(incomingFlags []string) []string {
 	// Reset the sourcedir for when we're testing. Otherwise the global state
 	// is preserved between the tests.
 	AhoyConf.srcDir = ""
+	AhoyConf.runDir = ""
+	AhoyConf.trustedKeys = nil
+	AhoyConf.configFiles = nil
+	AhoyConf.configLevels = nil
+	commandSources = map[string]*commandSource{}
 
 	// Grab the global flags first ourselves so we can customize the yaml file loaded.
 	// Flags are only parsed once, so we need to do this before cli has the chance to?
 	tempFlags := flagSet("tempFlags", globalFlags)
 	tempFlags.Parse(incomingFlags)
+
+	sourcefiles = nil
+	if f := tempFlags.Lookup("file"); f != nil {
+		if files, ok := f.Value.(*cli.StringSlice); ok {
+			sourcefiles = files.Value()
+		}
+	}
+	// AHOY_FILE is used when no files were passed using -f.
+	if envFiles := os.Getenv("AHOY_FILE"); len(sourcefiles) == 0 && envFiles != "" {
+		sourcefiles = strings.Split(envFiles, ",")
+	}
+	sourcefile = ""
+	if len(sourcefiles) > 0 {
+		sourcefile = sourcefiles[0]
+	}
+	return tempFlags.Args()
 }
 
 func overrideFlags(app *cli.App) {
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// configFileNames are the names of the files ahoy looks for, in priority order.
+var configFileNames = []string{".ahoy.yml", ".ahoy.yaml", "ahoy.yml", ".ahoy.json", ".ahoy.toml"}
+
+// findConfigFile returns the ahoy file in a directory, or an empty string if
+// there isn't one. Having more than one is an error, as it wouldn't be clear
+// which one is used.
+func findConfigFile(dir string) (string, error) {
+	found := []string{}
+	for _, name := range configFileNames {
+		if fileExists(filepath.Join(dir, name)) {
+			found = append(found, name)
+		}
+	}
+	if len(found) == 0 {
+		return "", nil
+	}
+	if len(found) > 1 {
+		return "", errors.New("Found more than one ahoy file in " + dir + ": " + strings.Join(found, ", ") + ". Remove all but one of them.")
+	}
+	return filepath.Join(dir, found[0]), nil
+}
+
+// unmarshalConfig parses an ahoy file based on its extension. JSON is parsed as
+// YAML, which it is a subset of, so both share the same field names.
+func unmarshalConfig(file string, data []byte, config *Config) error {
+	data, err := configYAML(file, data)
+	if err != nil {
+		return err
+	}
+	return unmarshalVersionedConfig(file, data, config)
+}
+
+// configYAML returns the content of an ahoy file as YAML, converting TOML files
+// so the same field names and types apply.
+func configYAML(file string, data []byte) ([]byte, error) {
+	if strings.ToLower(filepath.Ext(file)) != ".toml" {
+		return data, nil
+	}
+	values := map[string]any{}
+	if err := toml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(values)
+}
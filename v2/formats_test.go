@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestFindConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	if file, err := findConfigFile(dir); file != "" || err != nil {
+		t.Errorf("Expected no file in an empty directory, got %s: %v", file, err)
+	}
+
+	for _, name := range configFileNames {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, name), []byte("ahoyapi: v2\n"), 0o644)
+			file, err := findConfigFile(dir)
+			if err != nil || file != filepath.Join(dir, name) {
+				t.Errorf("Expected to find %s, got %s: %v", name, file, err)
+			}
+		})
+	}
+
+	os.WriteFile(filepath.Join(dir, ".ahoy.yml"), []byte("ahoyapi: v2\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, ".ahoy.json"), []byte("{\"ahoyapi\": \"v2\"}\n"), 0o644)
+	if _, err := findConfigFile(dir); err == nil {
+		t.Error("Expected an error when two ahoy files are in the same directory.")
+	}
+}
+
+func TestAlternateFormats(t *testing.T) {
+	for _, file := range []string{"testdata/simple.ahoy.json", "testdata/simple.ahoy.toml"} {
+		t.Run(file, func(t *testing.T) {
+			config, err := getConfig(file)
+			if err != nil {
+				t.Fatalf("Failed to load %s: %v", file, err)
+			}
+			if config.AhoyAPI != "v2" || !reflect.DeepEqual(config.Env, StringArray{".env"}) {
+				t.Errorf("Unexpected top level settings: %+v", config)
+			}
+			echo := config.Commands["echo"]
+			if echo.Cmd != `echo "$@"` || echo.Usage != "Display a message" || !reflect.DeepEqual(echo.Aliases, []string{"say"}) {
+				t.Errorf("Unexpected echo command: %+v", echo)
+			}
+			if commands, _ := getCommands(config); len(commands) != 2 {
+				t.Error("Expected two commands.")
+			}
+		})
+	}
+}
+
+func TestConfigYAMLFromTOML(t *testing.T) {
+	data, err := configYAML("test.ahoy.toml", []byte(`
+ahoyapi = "v2"
+usage = "TOML commands" # trailing comment
+
+[commands.deploy]
+usage = "Deploy the site"
+cmd = """
+echo one
+echo two"""
+aliases = ["d"]
+
+[[commands.deploy.inputs]]
+name = "environment"
+type = "select"
+choices = ["staging", "production"]
+
+[[commands.deploy.inputs]]
+name = "force"
+type = "confirm"
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	config := Config{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("Unexpected error unmarshalling the converted YAML: %v", err)
+	}
+	deploy := config.Commands["deploy"]
+	if config.Usage != "TOML commands" || deploy.Cmd != "echo one\necho two" || !reflect.DeepEqual(deploy.Aliases, []string{"d"}) {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+	if len(deploy.Inputs) != 2 || deploy.Inputs[0].Name != "environment" || deploy.Inputs[1].Type != "confirm" {
+		t.Errorf("Expected arrays of tables to give the inputs, got %+v", deploy.Inputs)
+	}
+
+	invalid := []string{
+		`key = `,
+		`key = "unterminated`,
+		`key = 1 2`,
+		"key = 1\nkey = 2",
+		"key = 017",
+		"key = 0_x1F",
+	}
+	for _, data := range invalid {
+		if _, err := configYAML("test.ahoy.toml", []byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestAhoyFileEnv(t *testing.T) {
+	originalSourcefile, originalSourcefiles := sourcefile, sourcefiles
+	defer func() { sourcefile, sourcefiles = originalSourcefile, originalSourcefiles }()
+
+	t.Setenv("AHOY_FILE", "testdata/simple.ahoy.toml,testdata/simple.ahoy.json")
+	initFlags([]string{"echo"})
+	if sourcefile != "testdata/simple.ahoy.toml" || len(sourcefiles) != 2 {
+		t.Errorf("Expected AHOY_FILE to be used, got %v", sourcefiles)
+	}
+
+	// -f takes precedence over AHOY_FILE.
+	initFlags([]string{"-f", "testdata/simple.ahoy.yml", "echo"})
+	if sourcefile != "testdata/simple.ahoy.yml" || len(sourcefiles) != 1 {
+		t.Errorf("Expected -f to take precedence, got %v", sourcefiles)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// matchGlob reports whether a slash separated path matches a pattern. As well
+// as the wildcards supported by path.Match, '**' matches any number of
+// directories, so "src/**/*.scss" matches both src/a.scss and src/b/c.scss.
+// A pattern that matches a directory matches everything in it.
+func matchGlob(pattern string, name string) bool {
+	patterns := strings.Split(path.Clean(pattern), "/")
+	names := strings.Split(name, "/")
+	for i := len(names); i > 0; i-- {
+		if matchSegments(patterns, names[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(patterns []string, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(patterns[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(patterns[0], names[0]); !ok {
+			return false
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// globRoot returns the part of a pattern before its first wildcard, so only
+// that part of the tree needs to be walked.
+func globRoot(pattern string) string {
+	root := []string{}
+	for _, segment := range strings.Split(path.Clean(pattern), "/") {
+		if strings.ContainsAny(segment, "*?[\\") {
+			break
+		}
+		root = append(root, segment)
+	}
+	return strings.Join(root, "/")
+}
+
+// globFiles returns the files in dir matching any of the patterns, relative to
+// dir and sorted. Patterns starting with '!' exclude the files they match.
+// Version control and ahoy's own state directories are always skipped.
+func globFiles(dir string, patterns []string) ([]string, error) {
+	includes, excludes := []string{}, []string{}
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(strings.TrimSpace(pattern))
+		if exclude, found := strings.CutPrefix(pattern, "!"); found {
+			excludes = append(excludes, exclude)
+		} else if pattern != "" {
+			includes = append(includes, pattern)
+		}
+	}
+
+	found := map[string]bool{}
+	for _, include := range includes {
+		root := filepath.Join(dir, filepath.FromSlash(globRoot(include)))
+		err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if file == root && errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if entry.IsDir() {
+				if file != root && (entry.Name() == ".git" || entry.Name() == ahoyStateDirName) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(dir, file)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if !matchGlob(include, rel) {
+				return nil
+			}
+			for _, exclude := range excludes {
+				if matchGlob(exclude, rel) {
+					return nil
+				}
+			}
+			found[rel] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files := []string{}
+	for file := range found {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		matches bool
+	}{
+		{"*.go", "ahoy.go", true},
+		{"*.go", "v2/ahoy.go", false},
+		{"src/**/*.scss", "src/a.scss", true},
+		{"src/**/*.scss", "src/b/c/d.scss", true},
+		{"src/**/*.scss", "lib/a.scss", false},
+		{"**/*.js", "a/b.js", true},
+		{"src", "src/b/c.txt", true},
+		{"src/*", "src/b/c.txt", true},
+		{"src/b", "src/bc.txt", false},
+		{"./src/*.css", "src/a.css", true},
+	}
+	for _, test := range tests {
+		if matches := matchGlob(test.pattern, test.name); matches != test.matches {
+			t.Errorf("Expected matchGlob(%q, %q) to be %v", test.pattern, test.name, test.matches)
+		}
+	}
+}
+
+func TestGlobFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"package.json", "src/app.js", "src/lib/util.js", "src/lib/util.test.js", "src/style.css", ".git/HEAD", ".ahoy/fingerprints/build"} {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(file), 0644)
+	}
+
+	files, err := globFiles(dir, []string{"src/**/*.js", "!**/*.test.js", "package.json", "missing/**"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"package.json", "src/app.js", "src/lib/util.js"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+
+	files, _ = globFiles(dir, []string{"**"})
+	for _, file := range files {
+		if matchGlob(".git", file) || matchGlob(".ahoy", file) {
+			t.Errorf("Expected %s to be skipped", file)
+		}
+	}
+}
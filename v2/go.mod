@@ -5,6 +5,7 @@ go 1.23
 toolchain go1.24.5
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/urfave/cli v1.22.9
 	gopkg.in/yaml.v2 v2.4.0
 )
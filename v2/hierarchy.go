@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/urfave/cli"
+)
+
+// configLevel is one ahoy file in a hierarchy of inherited files.
+type configLevel struct {
+	file   string
+	config Config
+}
+
+// getConfigHierarchy returns the ahoy files a config inherits from, ordered
+// from the top-most parent down to the config itself. Parent directories are
+// only searched when the config sets 'inherit: true', and the search stops at
+// the first file that sets 'root: true'.
+func getConfigHierarchy(file string, config Config) ([]configLevel, error) {
+	levels := []configLevel{{file: file, config: config}}
+	if !config.Inherit || config.Root {
+		return levels, nil
+	}
+
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return levels, err
+	}
+	dir := filepath.Dir(absFile)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+		ymlpath, err := findConfigFile(dir)
+		if err != nil {
+			return levels, err
+		}
+		if ymlpath == "" {
+			continue
+		}
+		parentConfig, err := getConfigWithOverlay(ymlpath)
+		if err != nil {
+			return levels, err
+		}
+		if verbose {
+			log.Println("===> Ahoy inheriting commands from", ymlpath)
+		}
+		levels = append([]configLevel{{file: ymlpath, config: parentConfig}}, levels...)
+		if parentConfig.Root {
+			break
+		}
+	}
+	return levels, nil
+}
+
+// getHierarchyCommands loads the commands from each level of a hierarchy, with
+// commands in child directories overriding those from their parents. Inherited
+// commands are grouped by the file they came from in the help output.
+func getHierarchyCommands(levels []configLevel) ([]cli.Command, error) {
+	srcDir := AhoyConf.srcDir
+	defer func() { AhoyConf.srcDir = srcDir }()
+	childDir, _ := filepath.Abs(filepath.Dir(levels[len(levels)-1].file))
+
+	commands := map[string]cli.Command{}
+	names := []string{}
+	for i := len(levels) - 1; i >= 0; i-- {
+		level := levels[i]
+		// Inherited commands run from, and resolve files relative to, their own directory.
+		AhoyConf.srcDir = filepath.Dir(level.file)
+		category := ""
+		if i < len(levels)-1 {
+			category = level.file
+			if rel, err := filepath.Rel(childDir, level.file); err == nil {
+				category = rel
+			}
+			category = "Inherited from " + category
+		}
+		levelCommands, levelSources, err := loadCommands(level.config, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, command := range levelCommands {
+			if _, exists := commands[command.Name]; exists {
+				commandSources[command.Name].override(levelSources[command.Name])
+				continue
+			}
+			command.Category = category
+			commands[command.Name] = command
+			names = append(names, command.Name)
+			commandSources[command.Name] = levelSources[command.Name]
+		}
+	}
+
+	hierarchyCommands := []cli.Command{}
+	for _, name := range names {
+		hierarchyCommands = append(hierarchyCommands, commands[name])
+	}
+	return hierarchyCommands, nil
+}
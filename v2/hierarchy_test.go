@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeHierarchyFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigHierarchy(t *testing.T) {
+	dir := t.TempDir()
+	writeHierarchyFile(t, filepath.Join(dir, ".ahoy.yml"), "ahoyapi: v2\ncommands:\n  above-root:\n    cmd: echo above\n")
+	writeHierarchyFile(t, filepath.Join(dir, "repo", ".ahoy.yml"), "ahoyapi: v2\nroot: true\ncommands:\n  build:\n    usage: Build everything\n    cmd: echo build\n  test:\n    usage: Test everything\n    cmd: echo parent\n")
+	writeHierarchyFile(t, filepath.Join(dir, "repo", "app", ".ahoy.yml"), "ahoyapi: v2\ninherit: true\ncommands:\n  test:\n    usage: Test the app\n    cmd: echo child\n")
+
+	childFile := filepath.Join(dir, "repo", "app", ".ahoy.yml")
+	config, err := getConfig(childFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels, err := getConfigHierarchy(childFile, config)
+	if err != nil {
+		t.Fatalf("Unexpected error loading the hierarchy: %v", err)
+	}
+	if len(levels) != 2 || levels[0].file != filepath.Join(dir, "repo", ".ahoy.yml") {
+		t.Fatalf("Expected the walk to stop at the root file, got %d levels", len(levels))
+	}
+
+	AhoyConf.srcDir = filepath.Dir(childFile)
+	commands, _ := getHierarchyCommands(levels)
+	if len(commands) != 2 {
+		t.Fatalf("Expected 2 commands, got %d", len(commands))
+	}
+	for _, command := range commands {
+		switch command.Name {
+		case "test":
+			if command.Usage != "Test the app" || command.Category != "" {
+				t.Errorf("Expected the child's test command to override the parent's, got %q in %q", command.Usage, command.Category)
+			}
+		case "build":
+			if command.Category != "Inherited from "+filepath.Join("..", ".ahoy.yml") {
+				t.Errorf("Expected build to be grouped by its file, got %q", command.Category)
+			}
+		default:
+			t.Errorf("Unexpected command %s", command.Name)
+		}
+	}
+	if AhoyConf.srcDir != filepath.Dir(childFile) {
+		t.Errorf("Expected srcDir to be restored, got %s", AhoyConf.srcDir)
+	}
+}
+
+func TestConfigHierarchyWithoutInherit(t *testing.T) {
+	config, err := getConfig("testdata/simple.ahoy.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels, err := getConfigHierarchy("testdata/simple.ahoy.yml", config)
+	if err != nil || len(levels) != 1 {
+		t.Errorf("Expected only the file itself without 'inherit: true', got %d levels", len(levels))
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Input is a value a command asks for when it isn't given, which is passed to
+// the command as an environment variable:
+//
+//	inputs:
+//	  - name: environment
+//	    type: select
+//	    choices: [staging, production]
+//	  - name: db-password
+//	    type: secret
+//
+// It can be given as --environment=production, or by setting ENVIRONMENT.
+type Input struct {
+	Name string
+	// Type is text, secret, select or confirm, defaulting to text.
+	Type string
+	// Prompt is the question asked, defaulting to the name.
+	Prompt string
+	// Default is used when only Enter is pressed, or when ahoy can't ask.
+	Default string
+	// Choices are the values a select can be.
+	Choices []string
+	// Env is the environment variable the value is passed in, defaulting to
+	// the name in upper case, with dashes replaced by underscores.
+	Env string
+	// Arg is the position of the command's argument that gives the value,
+	// such as 1 for $1, so it can be given as 'ahoy deploy production'.
+	Arg int
+}
+
+var inputNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func (i Input) envName() string {
+	if i.Env != "" {
+		return i.Env
+	}
+	return strings.ToUpper(strings.ReplaceAll(i.Name, "-", "_"))
+}
+
+func (i Input) prompt() string {
+	if i.Prompt != "" {
+		return i.Prompt
+	}
+	return i.Name
+}
+
+// check returns whether a value is allowed, converting confirm answers to
+// true or false.
+func (i Input) check(value string) (string, error) {
+	switch i.Type {
+	case "select":
+		if !slices.Contains(i.Choices, value) {
+			return "", errors.New("'" + value + "' isn't one of the choices for " + i.Name + ": " + strings.Join(i.Choices, ", "))
+		}
+	case "confirm":
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "y", "yes", "true", "1":
+			return "true", nil
+		case "n", "no", "false", "0":
+			return "false", nil
+		}
+		return "", errors.New("'" + value + "' isn't a yes or no answer for " + i.Name)
+	}
+	return value, nil
+}
+
+// validateInputs checks a command's inputs when its file is loaded.
+func validateInputs(inputs []Input) error {
+	names := map[string]bool{}
+	for _, input := range inputs {
+		if !inputNamePattern.MatchString(input.Name) {
+			return errors.New("'" + input.Name + "' isn't a valid name, use letters, numbers, dashes and underscores")
+		}
+		if names[input.Name] {
+			return errors.New(input.Name + " is listed more than once")
+		}
+		names[input.Name] = true
+		if input.Arg < 0 {
+			return errors.New(input.Name + " has the arg " + strconv.Itoa(input.Arg) + ", use the position of an argument such as 1")
+		}
+		switch input.Type {
+		case "", "text", "secret", "confirm":
+		case "select":
+			if len(input.Choices) == 0 {
+				return errors.New(input.Name + " is a select, but has no choices")
+			}
+		default:
+			return errors.New(input.Name + " has the type '" + input.Type + "', which isn't text, secret, select or confirm")
+		}
+		if input.Default != "" {
+			if _, err := input.check(input.Default); err != nil {
+				return errors.New("the default " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// inputArguments takes the values of inputs given as --name=value out of a
+// command's arguments. A confirm can also be given as just --name. Arguments
+// after "--" are left for the command. Inputs with an 'arg' that weren't
+// given as --name=value take the argument at that position, which is still
+// passed on to the command. Sources says where each value came from, as
+// --name or arg $N.
+func inputArguments(inputs []Input, args []string) (values map[string]string, sources map[string]string, rest []string) {
+	values = map[string]string{}
+	sources = map[string]string{}
+	rest = []string{}
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		flag, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		index := slices.IndexFunc(inputs, func(input Input) bool { return input.Name == flag })
+		if !strings.HasPrefix(arg, "--") || index < 0 || (!hasValue && inputs[index].Type != "confirm") {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			value = "true"
+		}
+		values[flag] = value
+		sources[flag] = "--" + flag
+	}
+
+	positional := []string{}
+	for _, arg := range rest {
+		if arg != "--" {
+			positional = append(positional, arg)
+		}
+	}
+	for _, input := range inputs {
+		if _, given := values[input.Name]; !given && input.Arg > 0 && input.Arg <= len(positional) {
+			values[input.Name] = positional[input.Arg-1]
+			sources[input.Name] = "arg $" + strconv.Itoa(input.Arg)
+		}
+	}
+	return values, sources, rest
+}
+
+// resolveInputs works out the value of each of a command's inputs, returning
+// them as environment variables. Values given as arguments are used first,
+// then those already in the environment, and otherwise ahoy asks for them.
+// When ahoy can't ask, defaults are used and any other inputs are an error.
+func resolveInputs(name string, inputs []Input, args map[string]string, envVars []string, in io.Reader, out io.Writer, interactive bool) ([]string, error) {
+	vars := []string{}
+	missing := []Input{}
+	for _, input := range inputs {
+		value, found := args[input.Name]
+		if !found {
+			value, found = lookupEnv(input.envName(), envVars)
+		}
+		if !found {
+			missing = append(missing, input)
+			continue
+		}
+		value, err := input.check(value)
+		if err != nil {
+			return nil, errors.New("Command '" + name + "' can't run, as " + err.Error() + ".")
+		}
+		vars = append(vars, input.envName()+"="+value)
+	}
+
+	if !interactive {
+		unanswered := []string{}
+		for _, input := range missing {
+			if input.Default == "" {
+				unanswered = append(unanswered, input.Name)
+				continue
+			}
+			value, _ := input.check(input.Default)
+			vars = append(vars, input.envName()+"="+value)
+		}
+		if len(unanswered) > 0 {
+			return nil, errors.New("Command '" + name + "' needs inputs that weren't given: " + strings.Join(unanswered, ", ") + ". ahoy can't ask for them as it isn't running in a terminal, so pass them as --name=value or set them in the environment.")
+		}
+		return vars, nil
+	}
+
+	reader := bufio.NewReader(in)
+	for _, input := range missing {
+		value, err := askInput(input, reader, out)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, input.envName()+"="+value)
+	}
+	return vars, nil
+}
+
+// lookupEnv finds a variable in the variables from env files, which win over
+// ahoy's own environment.
+func lookupEnv(name string, envVars []string) (string, bool) {
+	for i := len(envVars) - 1; i >= 0; i-- {
+		if value, found := strings.CutPrefix(envVars[i], name+"="); found {
+			return value, true
+		}
+	}
+	return os.LookupEnv(name)
+}
+
+// askInput asks for an input until it gets a value that is allowed.
+func askInput(input Input, reader *bufio.Reader, out io.Writer) (string, error) {
+	prompt := ">> " + input.prompt()
+	switch input.Type {
+	case "select":
+		fmt.Fprintln(out, prompt+":")
+		for i, choice := range input.Choices {
+			fmt.Fprintf(out, "   %d) %s\n", i+1, choice)
+		}
+		prompt = ">> Choose 1-" + strconv.Itoa(len(input.Choices))
+	case "confirm":
+		if value, _ := input.check(input.Default); value == "true" {
+			prompt += " [Y/n]"
+		} else {
+			prompt += " [y/N]"
+		}
+	}
+	if input.Default != "" && input.Type != "confirm" && input.Type != "secret" {
+		prompt += " [" + input.Default + "]"
+	}
+	if input.Type != "confirm" {
+		prompt += ":"
+	}
+	if input.Type == "secret" {
+		restore, err := disableEcho()
+		if err != nil {
+			return "", errors.New("Ahoy can't hide " + input.Name + " as it's typed (" + err.Error() + "), so pass it as --" + input.Name + "=value or set $" + input.envName() + " instead.")
+		}
+		// The terminal is put back even if ahoy is interrupted while waiting.
+		defer restore()
+	}
+
+	for {
+		fmt.Fprint(out, prompt+" ")
+		var answer string
+		var err error
+		if input.Type == "secret" {
+			answer, err = readSecret(reader, out)
+		} else {
+			answer, err = reader.ReadString('\n')
+		}
+		if err != nil && answer == "" {
+			var exit *exitStatus
+			if errors.As(err, &exit) {
+				return "", err
+			}
+			// Input was closed without an answer.
+			fmt.Fprintln(out)
+			return "", errors.New("No answer was given for " + input.Name + ".")
+		}
+		answer = strings.TrimRight(answer, "\r\n")
+		if input.Type != "secret" {
+			answer = strings.TrimSpace(answer)
+		}
+
+		switch {
+		case answer == "" && input.Default != "":
+			answer = input.Default
+		case answer == "" && input.Type == "confirm":
+			answer = "false"
+		case answer == "":
+			continue
+		case input.Type == "select":
+			if n, err := strconv.Atoi(answer); err == nil && n > 0 && n <= len(input.Choices) {
+				answer = input.Choices[n-1]
+			}
+		}
+		value, err := input.check(answer)
+		if err != nil {
+			fmt.Fprintln(out, err.Error()+".")
+			continue
+		}
+		return value, nil
+	}
+}
+
+type readResult struct {
+	line string
+	err  error
+}
+
+// readSecret reads a line once echo has been turned off, stopping if ahoy is
+// interrupted while waiting.
+func readSecret(reader *bufio.Reader, out io.Writer) (string, error) {
+	// The Enter typed isn't shown either.
+	defer fmt.Fprintln(out)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+	result := make(chan readResult, 1)
+	go func() {
+		line, err := reader.ReadString('\n')
+		result <- readResult{line: line, err: err}
+	}()
+	select {
+	case r := <-result:
+		return r.line, r.err
+	case sig := <-signals:
+		return "", &exitStatus{code: 128 + int(sig.(syscall.Signal))}
+	}
+}
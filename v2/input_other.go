@@ -0,0 +1,19 @@
+//go:build !unix && !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+// disableEcho can't stop the terminal showing what's typed on this platform,
+// so it fails rather than showing secrets. It does nothing when stdin isn't a
+// terminal, as nothing is shown.
+func disableEcho() (func(), error) {
+	if !isTerminal(os.Stdin) {
+		return func() {}, nil
+	}
+	return nil, errors.New("echo can't be turned off on " + runtime.GOOS)
+}
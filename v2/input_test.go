@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestInputsUnmarshal(t *testing.T) {
+	var config Config
+	data := `
+ahoyapi: v2
+commands:
+  deploy:
+    cmd: ./deploy.sh
+    inputs:
+      - name: environment
+        type: select
+        choices: [staging, production]
+        default: staging
+      - name: db-password
+        type: secret
+        env: DB_PASS
+      - name: migrate
+        type: confirm
+        default: yes
+`
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	inputs := config.Commands["deploy"].Inputs
+	if len(inputs) != 3 {
+		t.Fatalf("Expected 3 inputs, got %+v", inputs)
+	}
+	if inputs[0].envName() != "ENVIRONMENT" || inputs[1].envName() != "DB_PASS" || inputs[2].Default != "yes" {
+		t.Errorf("Expected the inputs to be read, got %+v", inputs)
+	}
+	if err := validateInputs(inputs); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	tests := map[string][]Input{
+		"no name":           {{Type: "text"}},
+		"invalid name":      {{Name: "db name"}},
+		"duplicate":         {{Name: "site"}, {Name: "site"}},
+		"unknown type":      {{Name: "site", Type: "number"}},
+		"select no choices": {{Name: "site", Type: "select"}},
+		"bad default":       {{Name: "site", Type: "select", Choices: []string{"a"}, Default: "b"}},
+		"bad confirm":       {{Name: "sure", Type: "confirm", Default: "maybe"}},
+		"negative arg":      {{Name: "site", Arg: -1}},
+	}
+	for name, inputs := range tests {
+		if err := validateInputs(inputs); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if err := validateInputs([]Input{{Name: "db-name", Default: "drupal"}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInputArguments(t *testing.T) {
+	inputs := []Input{{Name: "site"}, {Name: "migrate", Type: "confirm"}}
+	values, sources, rest := inputArguments(inputs, []string{"--site=example.com", "dump.sql", "--migrate", "--site", "--other=1", "--", "--site=kept"})
+	if expected := map[string]string{"site": "example.com", "migrate": "true"}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	if expected := map[string]string{"site": "--site", "migrate": "--migrate"}; !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Expected sources %v, got %v", expected, sources)
+	}
+	if expected := []string{"dump.sql", "--site", "--other=1", "--", "--site=kept"}; !reflect.DeepEqual(rest, expected) {
+		t.Errorf("Expected %v, got %v", expected, rest)
+	}
+}
+
+func TestInputArgumentsByPosition(t *testing.T) {
+	inputs := []Input{{Name: "environment", Arg: 1}, {Name: "file", Arg: 2}, {Name: "branch", Arg: 3}}
+	values, sources, rest := inputArguments(inputs, []string{"--file=dump.sql", "production", "--", "main"})
+	if expected := map[string]string{"environment": "production", "file": "dump.sql"}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	if expected := map[string]string{"environment": "arg $1", "file": "--file"}; !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Expected sources %v, got %v", expected, sources)
+	}
+	// Arguments used for inputs are still passed on to the command.
+	if expected := []string{"production", "--", "main"}; !reflect.DeepEqual(rest, expected) {
+		t.Errorf("Expected %v, got %v", expected, rest)
+	}
+}
+
+func TestResolveInputs(t *testing.T) {
+	t.Setenv("AHOY_TEST_SITE", "from-env")
+	inputs := []Input{
+		{Name: "site", Env: "AHOY_TEST_SITE"},
+		{Name: "environment", Type: "select", Choices: []string{"staging", "production"}},
+		{Name: "migrate", Type: "confirm", Default: "no"},
+	}
+
+	vars, err := resolveInputs("deploy", inputs, map[string]string{"environment": "production"}, nil, strings.NewReader(""), &bytes.Buffer{}, false)
+	if expected := []string{"AHOY_TEST_SITE=from-env", "ENVIRONMENT=production", "MIGRATE=false"}; err != nil || !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v: %v", expected, vars, err)
+	}
+
+	vars, err = resolveInputs("deploy", inputs, nil, []string{"ENVIRONMENT=staging", "AHOY_TEST_SITE=from-file"}, strings.NewReader(""), &bytes.Buffer{}, false)
+	if expected := []string{"AHOY_TEST_SITE=from-file", "ENVIRONMENT=staging", "MIGRATE=false"}; err != nil || !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected env files to win, got %v: %v", vars, err)
+	}
+
+	if _, err := resolveInputs("deploy", inputs, map[string]string{"environment": "dev"}, nil, strings.NewReader(""), &bytes.Buffer{}, false); err == nil || !strings.Contains(err.Error(), "'dev' isn't one of the choices") {
+		t.Errorf("Expected an invalid choice to fail, got %v", err)
+	}
+
+	_, err = resolveInputs("deploy", append(inputs, Input{Name: "token", Type: "secret"}), nil, nil, strings.NewReader(""), &bytes.Buffer{}, false)
+	if err == nil || !strings.Contains(err.Error(), "weren't given: environment, token.") {
+		t.Errorf("Expected the missing inputs to be named, got %v", err)
+	}
+}
+
+func TestAskInputs(t *testing.T) {
+	inputs := []Input{
+		{Name: "site", Prompt: "Which site?", Default: "example.com"},
+		{Name: "db-name"},
+		{Name: "environment", Type: "select", Choices: []string{"staging", "production"}},
+		{Name: "migrate", Type: "confirm"},
+		{Name: "token", Type: "secret"},
+	}
+	// The empty db-name is asked again, as is the choice that's out of range.
+	answers := "\n\ndrupal\n3\n2\ny\n s3cret \n"
+	out := &bytes.Buffer{}
+	vars, err := resolveInputs("deploy", inputs, nil, nil, strings.NewReader(answers), out, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"SITE=example.com", "DB_NAME=drupal", "ENVIRONMENT=production", "MIGRATE=true", "TOKEN= s3cret "}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+	for _, prompt := range []string{">> Which site? [example.com]: ", "   2) production\n", ">> Choose 1-2: ", "'3' isn't one of the choices", ">> migrate [y/N] ", ">> token: "} {
+		if !strings.Contains(out.String(), prompt) {
+			t.Errorf("Expected %q to be shown, got %q", prompt, out.String())
+		}
+	}
+
+	if _, err := resolveInputs("deploy", inputs[1:2], nil, nil, strings.NewReader(""), &bytes.Buffer{}, true); err == nil {
+		t.Error("Expected closed input to fail")
+	}
+}
+
+func TestDisableEchoWithoutTerminal(t *testing.T) {
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	os.Stdin, _ = os.Open(os.DevNull)
+	defer os.Stdin.Close()
+
+	// Nothing typed is shown when stdin isn't a terminal, so there's nothing to hide.
+	restore, err := disableEcho()
+	if err != nil || restore == nil {
+		t.Fatalf("Expected echo to be left alone without a terminal, got %v", err)
+	}
+	restore()
+}
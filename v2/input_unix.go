@@ -0,0 +1,27 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// disableEcho stops the terminal showing what's typed, returning a function
+// that turns it back on. It does nothing when stdin isn't a terminal, as
+// nothing is shown.
+func disableEcho() (func(), error) {
+	stty := func(arg string) error {
+		command := exec.Command("stty", arg)
+		command.Stdin = os.Stdin
+		return command.Run()
+	}
+	if !isTerminal(os.Stdin) {
+		return func() {}, nil
+	}
+	if err := stty("-echo"); err != nil {
+		return nil, errors.New("stty failed: " + err.Error())
+	}
+	return func() { stty("echo") }, nil
+}
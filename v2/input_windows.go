@@ -0,0 +1,29 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// enableEchoInput is the console mode flag that shows what's typed.
+const enableEchoInput = 0x0004
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// disableEcho stops the console showing what's typed, returning a function
+// that turns it back on. It does nothing when stdin isn't a console, as
+// nothing is shown.
+func disableEcho() (func(), error) {
+	handle := syscall.Handle(os.Stdin.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return func() {}, nil
+	}
+	if ok, _, err := setConsoleMode.Call(uintptr(handle), uintptr(mode&^enableEchoInput)); ok == 0 {
+		return nil, errors.New("SetConsoleMode failed: " + err.Error())
+	}
+	return func() { setConsoleMode.Call(uintptr(handle), uintptr(mode)) }, nil
+}
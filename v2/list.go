@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// listedCommand is a command as shown by 'ahoy list', for tools such as
+// editors and shell pickers.
+type listedCommand struct {
+	Name        string   `json:"name" yaml:"name"`
+	Path        string   `json:"path" yaml:"path"`
+	Aliases     []string `json:"aliases" yaml:"aliases"`
+	Usage       string   `json:"usage" yaml:"usage"`
+	Description string   `json:"description" yaml:"description"`
+	Category    string   `json:"category" yaml:"category"`
+	Hidden      bool     `json:"hidden" yaml:"hidden"`
+	Optional    bool     `json:"optional" yaml:"optional"`
+	// Builtin is true for the commands ahoy adds itself, such as 'doctor'.
+	Builtin bool `json:"builtin" yaml:"builtin"`
+	// Args describes the arguments of built in commands. Commands from ahoy
+	// files are passed any arguments.
+	Args        string          `json:"args" yaml:"args"`
+	Flags       []listedFlag    `json:"flags" yaml:"flags"`
+	Source      *listedSource   `json:"source" yaml:"source"`
+	Subcommands []listedCommand `json:"subcommands" yaml:"subcommands"`
+}
+
+// listedFlag is a flag of a built in command, or an input of a command from an
+// ahoy file, which can be given as --name=value.
+type listedFlag struct {
+	Name    string   `json:"name" yaml:"name"`
+	Type    string   `json:"type" yaml:"type"`
+	Usage   string   `json:"usage" yaml:"usage"`
+	Default string   `json:"default" yaml:"default"`
+	Choices []string `json:"choices" yaml:"choices"`
+	Env     string   `json:"env" yaml:"env"`
+}
+
+type listedSource struct {
+	File string `json:"file" yaml:"file"`
+	Line int    `json:"line" yaml:"line"`
+}
+
+// listCommands describes a list of commands, and their subcommands, with the
+// sources they came from.
+func listCommands(commands []cli.Command, sources map[string]*commandSource, parents []string) []listedCommand {
+	listed := []listedCommand{}
+	for _, command := range commands {
+		path := append(append([]string{}, parents...), command.Name)
+		item := listedCommand{
+			Name:        command.Name,
+			Path:        strings.Join(path, " "),
+			Aliases:     append([]string{}, command.Aliases...),
+			Usage:       command.Usage,
+			Description: command.Description,
+			Category:    command.Category,
+			Hidden:      command.HideHelp || command.Hidden,
+			Args:        command.ArgsUsage,
+			Flags:       []listedFlag{},
+		}
+		var subSources map[string]*commandSource
+		if source := sources[command.Name]; source != nil {
+			item.Optional = source.cmd.Optional
+			item.Source = &listedSource{File: absoluteSource(source.file), Line: source.line}
+			for _, input := range source.cmd.Inputs {
+				inputType := input.Type
+				if inputType == "" {
+					inputType = "text"
+				}
+				item.Flags = append(item.Flags, listedFlag{
+					Name:    input.Name,
+					Type:    inputType,
+					Usage:   input.Prompt,
+					Default: input.Default,
+					Choices: append([]string{}, input.Choices...),
+					Env:     input.envName(),
+				})
+			}
+			subSources = source.subcommands
+		} else {
+			item.Builtin = true
+			for _, flag := range command.Flags {
+				item.Flags = append(item.Flags, listFlag(flag))
+			}
+		}
+		item.Subcommands = listCommands(command.Subcommands, subSources, path)
+		listed = append(listed, item)
+	}
+	return listed
+}
+
+// absoluteSource returns the absolute path of a local file, so the listing
+// doesn't depend on where ahoy was run from.
+func absoluteSource(file string) string {
+	if file == stdinConfigFile || isRemoteImport(file) {
+		return file
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
+
+func listFlag(flag cli.Flag) listedFlag {
+	names := strings.Split(flag.GetName(), ",")
+	listed := listedFlag{Name: strings.TrimSpace(names[0]), Type: "string", Choices: []string{}}
+	switch f := flag.(type) {
+	case cli.BoolFlag:
+		listed.Type, listed.Usage, listed.Env = "bool", f.Usage, f.EnvVar
+	case cli.StringFlag:
+		listed.Usage, listed.Default, listed.Env = f.Usage, f.Value, f.EnvVar
+	case cli.StringSliceFlag:
+		listed.Usage, listed.Env = f.Usage, f.EnvVar
+	case cli.DurationFlag:
+		listed.Type, listed.Usage, listed.Default, listed.Env = "duration", f.Usage, f.Value.String(), f.EnvVar
+	}
+	return listed
+}
+
+// printCommandTree prints commands as an indented tree, with their usage and
+// where they're defined.
+func printCommandTree(out io.Writer, commands []listedCommand) {
+	w := tabwriter.NewWriter(out, 1, 8, 2, ' ', 0)
+	cwd, _ := os.Getwd()
+	var print func(commands []listedCommand, prefix string, top bool)
+	print = func(commands []listedCommand, prefix string, top bool) {
+		for i, command := range commands {
+			branch, next := "", ""
+			if !top && i < len(commands)-1 {
+				branch, next = "├── ", "│   "
+			} else if !top {
+				branch, next = "└── ", "    "
+			}
+			name := strings.Join(append([]string{command.Name}, command.Aliases...), ", ")
+			if command.Hidden {
+				name += " (hidden)"
+			}
+			source := "built in"
+			if command.Source != nil {
+				file := command.Source.File
+				if rel, err := filepath.Rel(cwd, file); err == nil && !strings.HasPrefix(rel, "..") {
+					file = rel
+				}
+				source = definition{file: file, line: command.Source.Line}.String()
+			}
+			fmt.Fprintf(w, "%s%s%s\t%s\t%s\n", prefix, branch, name, command.Usage, source)
+			print(command.Subcommands, prefix+next, false)
+		}
+	}
+	print(commands, "", true)
+	w.Flush()
+}
+
+func listAction(c *cli.Context) error {
+	commands := listCommands(c.App.Commands, commandSources, nil)
+	switch {
+	case c.Bool("json") && c.Bool("yaml"):
+		return errors.New("Use either --json or --yaml, not both.")
+	case c.Bool("json"):
+		data, err := json.MarshalIndent(map[string]any{"commands": commands}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case c.Bool("yaml"):
+		data, err := yaml.Marshal(map[string]any{"commands": commands})
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+	default:
+		printCommandTree(os.Stdout, commands)
+	}
+	return nil
+}
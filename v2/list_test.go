@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func writeListConfig(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, ".ahoy.yml")
+	os.WriteFile(main, []byte(`ahoyapi: v2
+commands:
+  deploy:
+    usage: Deploy the site
+    aliases: [d]
+    cmd: ./deploy.sh
+    inputs:
+      - name: environment
+        type: select
+        choices: [staging, production]
+  secret:
+    hide: true
+    cmd: echo secret
+  docker:
+    usage: Docker commands
+    optional: true
+    imports: [docker.ahoy.yml]
+`), 0644)
+	docker := filepath.Join(dir, "docker.ahoy.yml")
+	os.WriteFile(docker, []byte("ahoyapi: v2\ncommands:\n  up:\n    usage: Start the containers\n    cmd: docker compose up\n"), 0644)
+	return main, docker
+}
+
+func TestListJSON(t *testing.T) {
+	main, docker := writeListConfig(t)
+	out, _ := appRun([]string{"ahoy", "-f", main, "list", "--json"})
+	var listing struct {
+		Commands []listedCommand
+	}
+	if err := json.Unmarshal([]byte(out), &listing); err != nil {
+		t.Fatalf("Expected JSON, got %v:\n%s", err, out)
+	}
+	commands := map[string]listedCommand{}
+	for _, command := range listing.Commands {
+		commands[command.Name] = command
+	}
+
+	deploy := commands["deploy"]
+	if deploy.Usage != "Deploy the site" || len(deploy.Aliases) != 1 || deploy.Source == nil || deploy.Source.File != main || deploy.Source.Line != 3 {
+		t.Errorf("Expected deploy to be listed with its source, got %+v", deploy)
+	}
+	if len(deploy.Flags) != 1 || deploy.Flags[0].Name != "environment" || deploy.Flags[0].Type != "select" || deploy.Flags[0].Env != "ENVIRONMENT" {
+		t.Errorf("Expected the inputs of deploy to be listed as flags, got %+v", deploy.Flags)
+	}
+	if !commands["secret"].Hidden {
+		t.Error("Expected hidden commands to be listed as hidden")
+	}
+	dockerCmd := commands["docker"]
+	if !dockerCmd.Optional || len(dockerCmd.Subcommands) != 1 {
+		t.Fatalf("Expected docker to be optional with a subcommand, got %+v", dockerCmd)
+	}
+	if up := dockerCmd.Subcommands[0]; up.Path != "docker up" || up.Source == nil || up.Source.File != docker {
+		t.Errorf("Expected the imported subcommand to be listed with its source, got %+v", up)
+	}
+	if doctor := commands["doctor"]; !doctor.Builtin || doctor.Source != nil {
+		t.Errorf("Expected built in commands to be listed as built in, got %+v", doctor)
+	}
+	if watch := commands["watch"]; len(watch.Flags) != 2 || watch.Flags[0].Type != "duration" || watch.Flags[0].Default != "500ms" {
+		t.Errorf("Expected the flags of built in commands to be listed, got %+v", watch.Flags)
+	}
+}
+
+func TestListYAMLAndTree(t *testing.T) {
+	main, _ := writeListConfig(t)
+	out, _ := appRun([]string{"ahoy", "-f", main, "list", "--yaml"})
+	var listing map[string][]map[string]any
+	if err := yaml.Unmarshal([]byte(out), &listing); err != nil || len(listing["commands"]) == 0 {
+		t.Fatalf("Expected YAML, got %v:\n%s", err, out)
+	}
+	if listing["commands"][0]["name"] != "deploy" {
+		t.Errorf("Expected the commands in the order they're shown in help, got %v", listing["commands"][0])
+	}
+
+	out, _ = appRun([]string{"ahoy", "-f", main, "list"})
+	for _, expected := range []string{"deploy, d", "secret (hidden)", "└── up", "Start the containers", "built in"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in the tree, got:\n%s", expected, out)
+		}
+	}
+}
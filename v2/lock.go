@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// lockPollInterval is how often ahoy checks whether a lock has been released.
+const lockPollInterval = 200 * time.Millisecond
+
+// noWait is set using --no-wait or AHOY_NO_WAIT, to fail straight away when
+// a command's lock is held instead of waiting for it.
+var noWait bool
+
+// lockName returns the name of the lock a command takes. 'lock: true' uses
+// the command's own name, and any other value names a lock shared by all the
+// commands using it.
+func lockName(lock string, command string) string {
+	switch lock {
+	case "", "false":
+		return ""
+	case "true":
+		return command
+	}
+	return lock
+}
+
+func lockPath(dir string, name string) string {
+	return filepath.Join(dir, ahoyStateDirName, "locks", stateFileName(name)+".lock")
+}
+
+// readLockHolder returns the PID and command that hold a lock.
+func readLockHolder(path string) (int, string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, ""
+	}
+	pid, command, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	n, _ := strconv.Atoi(pid)
+	return n, command
+}
+
+func describeLockHolder(path string) string {
+	pid, command := readLockHolder(path)
+	if pid == 0 {
+		return "another process"
+	}
+	if command == "" {
+		return "PID " + strconv.Itoa(pid)
+	}
+	return "PID " + strconv.Itoa(pid) + " running '" + command + "'"
+}
+
+// acquireLock takes a command's lock in the project's state directory,
+// waiting for it to be released if another process holds it, unless --no-wait
+// is set. The returned function releases the lock.
+func acquireLock(dir string, name string, command string) (func(), error) {
+	path := lockPath(dir, name)
+	if err := ensureStateDir(dir); err != nil {
+		return nil, errors.New("Could not take the lock '" + name + "': " + err.Error())
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.New("Could not take the lock '" + name + "': " + err.Error())
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+	waiting := false
+	for {
+		f, locked, err := tryLock(path)
+		if err != nil {
+			return nil, errors.New("Could not take the lock '" + name + "': " + err.Error())
+		}
+		if locked {
+			// A holder that was killed leaves its PID and command behind.
+			f.Truncate(0)
+			f.WriteString(strconv.Itoa(os.Getpid()) + "\n" + command + "\n")
+			return func() { releaseLock(f) }, nil
+		}
+		if noWait {
+			return nil, newAhoyError(exitLocked, errors.New("Command '"+command+"' can't run, as the lock '"+name+"' is held by "+describeLockHolder(path)+"."))
+		}
+		if !waiting {
+			logger("info", "Waiting for the lock '"+name+"', which is held by "+describeLockHolder(path)+". Use --no-wait to fail instead.")
+			waiting = true
+		}
+		select {
+		case <-time.After(lockPollInterval):
+		case sig := <-signals:
+			return nil, &exitStatus{code: 128 + int(sig.(syscall.Signal))}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// tryLock creates a lock file without waiting, returning false if another
+// process holds it. Lock files left by ahoy processes that no longer exist
+// are removed.
+func tryLock(path string) (*os.File, bool, error) {
+	for retried := false; ; retried = true {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return f, true, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, false, err
+		}
+		pid, _ := readLockHolder(path)
+		if retried || pid == 0 {
+			return nil, false, nil
+		}
+		if _, err := os.FindProcess(pid); err == nil {
+			return nil, false, nil
+		}
+		os.Remove(path)
+	}
+}
+
+// releaseLock removes a lock file created by tryLock.
+func releaseLock(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
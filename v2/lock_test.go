@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockName(t *testing.T) {
+	tests := map[string]string{
+		"":      "",
+		"false": "",
+		"true":  "db:import",
+		"db":    "db",
+	}
+	for lock, expected := range tests {
+		if name := lockName(lock, "db:import"); name != expected {
+			t.Errorf("Expected lock %q to be named %q, got %q", lock, expected, name)
+		}
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	defer func(wait bool) { noWait = wait }(noWait)
+	dir := t.TempDir()
+
+	first, err := acquireLock(dir, "db", "db:import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fileExists(lockPath(dir, "db")) || !fileExists(dir+"/.ahoy/.gitignore") {
+		t.Error("Expected the lock file to be in the state directory")
+	}
+
+	noWait = true
+	_, err = acquireLock(dir, "db", "db:reset")
+	if code, _ := exitCode(err); code != exitLocked {
+		t.Errorf("Expected a held lock to fail with --no-wait, got %d: %v", code, err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "PID "+strconv.Itoa(os.Getpid())+" running 'db:import'") {
+		t.Errorf("Expected the error to say who holds the lock, got: %v", err)
+	}
+
+	noWait = false
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		first()
+	}()
+	start := time.Now()
+	second, err := acquireLock(dir, "db", "db:reset")
+	if err != nil {
+		t.Fatalf("Expected the lock to be taken once it was released, got: %v", err)
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Error("Expected to wait for the lock to be released")
+	}
+	second()
+}
+
+func TestAcquireLockReplacesStaleHolder(t *testing.T) {
+	dir := t.TempDir()
+	path := lockPath(dir, "db")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	// Left behind by a holder that was killed before releasing the lock.
+	os.WriteFile(path, []byte("1234567890\nsome-very-long-command-name\n"), 0644)
+
+	release, err := acquireLock(dir, "db", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	pid, command := readLockHolder(path)
+	if pid != os.Getpid() || command != "db" {
+		t.Errorf("Expected the lock to be held by PID %d running 'db', got PID %d running %q", os.Getpid(), pid, command)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock takes an exclusive flock on a file without waiting, returning false
+// if another process holds it. The lock is released by the OS if ahoy exits
+// without releasing it.
+func tryLock(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return f, true, nil
+}
+
+// releaseLock releases a lock taken by tryLock. The file is kept, as removing
+// it could let two processes lock different files with the same name.
+func releaseLock(f *os.File) {
+	f.Truncate(0)
+	f.Close()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// localOverlayPath returns the path of the untracked local overlay for an ahoy
+// file, e.g. .ahoy.local.yml for .ahoy.yml or foo.ahoy.local.yml for foo.ahoy.yml.
+func localOverlayPath(file string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + ".local" + ext
+}
+
+// getConfigWithOverlay loads an ahoy file and merges its local overlay over it,
+// if there is one.
+func getConfigWithOverlay(file string) (Config, error) {
+	config, err := getConfig(file)
+	if err != nil || file == stdinConfigFile {
+		return config, err
+	}
+	overlayFile := localOverlayPath(file)
+	if !fileExists(overlayFile) {
+		return config, nil
+	}
+	overlay, err := getConfig(overlayFile)
+	if err != nil {
+		return config, err
+	}
+	trackConfigFile(overlayFile)
+	if verbose {
+		log.Println("===> Ahoy merged", overlayFile, "over", file)
+	}
+	return mergeConfig(config, overlay), nil
+}
+
+// mergeSourcefiles merges any further files passed using -f over the config
+// loaded from the first one, in order.
+func mergeSourcefiles(config Config) (Config, error) {
+	if len(sourcefiles) < 2 {
+		return config, nil
+	}
+	stdinUsed := sourcefile == stdinConfigFile
+	for _, file := range sourcefiles[1:] {
+		if file == stdinConfigFile {
+			if stdinUsed {
+				return config, errors.New("The config can only be read from stdin once, but '-f -' was passed more than once.")
+			}
+			stdinUsed = true
+		}
+		path, err := getConfigPath(file)
+		if err != nil {
+			return config, err
+		}
+		overlay, err := getConfigWithOverlay(path)
+		if err != nil {
+			return config, err
+		}
+		if path != stdinConfigFile {
+			trackConfigFile(path)
+		}
+		if verbose {
+			log.Println("===> Ahoy merged", path, "over", sourcefile)
+		}
+		config = mergeConfig(config, overlay)
+	}
+	return config, nil
+}
+
+// mergeConfig merges the overlay config over the base config. Settings in the
+// overlay replace those in the base, while env files, trusted keys and
+// requirements are added.
+func mergeConfig(base Config, overlay Config) Config {
+	merged := base
+	if overlay.Usage != "" {
+		merged.Usage = overlay.Usage
+	}
+	if overlay.Entrypoint != nil {
+		merged.Entrypoint = overlay.Entrypoint
+	}
+	if overlay.RequiresAhoy != "" {
+		merged.RequiresAhoy = overlay.RequiresAhoy
+	}
+	merged.Env = append(append(StringArray{}, base.Env...), overlay.Env...)
+	merged.TrustedKeys = append(append(StringArray{}, base.TrustedKeys...), overlay.TrustedKeys...)
+	merged.Requires = append(append([]Requirement{}, base.Requires...), overlay.Requires...)
+	merged.Inherit = base.Inherit || overlay.Inherit
+	merged.Root = base.Root || overlay.Root
+
+	merged.Commands = map[string]Command{}
+	for name, cmd := range base.Commands {
+		merged.Commands[name] = cmd
+	}
+	for name, cmd := range overlay.Commands {
+		if baseCmd, exists := merged.Commands[name]; exists {
+			cmd = mergeCommand(baseCmd, cmd)
+		}
+		merged.Commands[name] = cmd
+	}
+	return merged
+}
+
+// mergeCommand merges an overlay command over a command of the same name.
+func mergeCommand(base Command, overlay Command) Command {
+	merged := base
+	if overlay.Description != "" {
+		merged.Description = overlay.Description
+	}
+	if overlay.Usage != "" {
+		merged.Usage = overlay.Usage
+	}
+	// A command either runs 'cmd' or has 'imports', so setting one replaces the other.
+	if overlay.Cmd != "" {
+		merged.Cmd = overlay.Cmd
+		merged.Imports = nil
+	}
+	if overlay.Imports != nil {
+		merged.Imports = overlay.Imports
+		merged.Cmd = ""
+	}
+	if overlay.Aliases != nil {
+		merged.Aliases = overlay.Aliases
+	}
+	if overlay.Cleanup != "" {
+		merged.Cleanup = overlay.Cleanup
+	}
+	if overlay.Timeout != "" {
+		merged.Timeout = overlay.Timeout
+	}
+	if overlay.Retry != nil {
+		merged.Retry = overlay.Retry
+	}
+	if overlay.Sources != nil {
+		merged.Sources = overlay.Sources
+	}
+	if overlay.Generates != nil {
+		merged.Generates = overlay.Generates
+	}
+	if overlay.Watch != nil {
+		merged.Watch = overlay.Watch
+	}
+	if overlay.Cache != nil {
+		merged.Cache = overlay.Cache
+	}
+	if overlay.Lock != "" {
+		merged.Lock = overlay.Lock
+	}
+	if overlay.Confirm != nil {
+		merged.Confirm = overlay.Confirm
+	}
+	if overlay.Inputs != nil {
+		merged.Inputs = overlay.Inputs
+	}
+	if overlay.Complete != "" {
+		merged.Complete = overlay.Complete
+	}
+	if overlay.defined.file != "" {
+		merged.overlays = append(append([]definition{}, base.overlays...), overlay.defined)
+	}
+	merged.Env = append(append(StringArray{}, base.Env...), overlay.Env...)
+	merged.Requires = append(append([]Requirement{}, base.Requires...), overlay.Requires...)
+	merged.Hide = base.Hide || overlay.Hide
+	merged.Optional = base.Optional || overlay.Optional
+	return merged
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLocalOverlayPath(t *testing.T) {
+	tests := map[string]string{
+		".ahoy.yml":                 ".ahoy.local.yml",
+		"/project/.ahoy.yml":        "/project/.ahoy.local.yml",
+		"testdata/simple.ahoy.yml":  "testdata/simple.ahoy.local.yml",
+		"testdata/simple.ahoy.yaml": "testdata/simple.ahoy.local.yaml",
+	}
+	for file, expected := range tests {
+		if actual := localOverlayPath(file); actual != expected {
+			t.Errorf("localOverlayPath(%s): expected %s, got %s", file, expected, actual)
+		}
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	base := Config{
+		Usage:   "Base usage.",
+		AhoyAPI: "v2",
+		Env:     StringArray{".env"},
+		Commands: map[string]Command{
+			"build": {Usage: "Build it", Cmd: "make", Aliases: []string{"b"}},
+			"tools": {Usage: "Tools", Imports: []string{"tools.ahoy.yml"}},
+		},
+	}
+	overlay := Config{
+		AhoyAPI: "v2",
+		Env:     StringArray{".env.local"},
+		Commands: map[string]Command{
+			"build": {Cmd: "make -j8", Env: StringArray{".env.build"}},
+			"tools": {Cmd: "echo no tools"},
+			"mine":  {Usage: "My own command", Cmd: "echo mine"},
+		},
+	}
+
+	merged := mergeConfig(base, overlay)
+	if merged.Usage != "Base usage." {
+		t.Errorf("Expected the base usage to be kept, got %q", merged.Usage)
+	}
+	if !reflect.DeepEqual(merged.Env, StringArray{".env", ".env.local"}) {
+		t.Errorf("Expected env files to be appended, got %v", merged.Env)
+	}
+	build := merged.Commands["build"]
+	if build.Cmd != "make -j8" || build.Usage != "Build it" || !reflect.DeepEqual(build.Aliases, []string{"b"}) {
+		t.Errorf("Expected only the cmd of build to be overridden, got %+v", build)
+	}
+	if tools := merged.Commands["tools"]; tools.Cmd != "echo no tools" || tools.Imports != nil {
+		t.Errorf("Expected the cmd to replace the imports, got %+v", tools)
+	}
+	if _, exists := merged.Commands["mine"]; !exists {
+		t.Error("Expected the new command to be added.")
+	}
+	if base.Commands["build"].Cmd != "make" || len(base.Env) != 1 {
+		t.Error("Expected the base config not to be modified.")
+	}
+}
+
+func TestGetConfigWithOverlay(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, ".ahoy.yml")
+	os.WriteFile(file, []byte("ahoyapi: v2\ncommands:\n  hello:\n    usage: Say hello\n    cmd: echo hello\n"), 0o644)
+
+	config, err := getConfigWithOverlay(file)
+	if err != nil || config.Commands["hello"].Cmd != "echo hello" {
+		t.Fatalf("Expected the file to load without an overlay, got %+v: %v", config, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, ".ahoy.local.yml"), []byte("ahoyapi: v2\ncommands:\n  hello:\n    cmd: echo howdy\n"), 0o644)
+	config, err = getConfigWithOverlay(file)
+	if err != nil {
+		t.Fatalf("Unexpected error loading the overlay: %v", err)
+	}
+	if config.Commands["hello"].Cmd != "echo howdy" || config.Commands["hello"].Usage != "Say hello" {
+		t.Errorf("Expected the overlay to be merged, got %+v", config.Commands["hello"])
+	}
+}
+
+func TestMergeSourcefiles(t *testing.T) {
+	originalSourcefile, originalSourcefiles := sourcefile, sourcefiles
+	defer func() { sourcefile, sourcefiles = originalSourcefile, originalSourcefiles }()
+
+	dir := t.TempDir()
+	generated := filepath.Join(dir, "generated.ahoy.yml")
+	os.WriteFile(generated, []byte("ahoyapi: v2\ncommands:\n  echo:\n    cmd: echo generated\n  deploy:\n    cmd: echo deploy\n"), 0o644)
+
+	initFlags([]string{"-f", "testdata/simple.ahoy.yml", "-f", generated, "echo"})
+	if sourcefile != "testdata/simple.ahoy.yml" || len(sourcefiles) != 2 {
+		t.Fatalf("Expected both -f files to be parsed, got %v", sourcefiles)
+	}
+
+	config, err := getConfig(sourcefile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err = mergeSourcefiles(config)
+	if err != nil {
+		t.Fatalf("Unexpected error merging files: %v", err)
+	}
+	if config.Commands["echo"].Cmd != "echo generated" || config.Commands["echo"].Usage != "Display a message" {
+		t.Errorf("Expected the later file to override the cmd of echo, got %+v", config.Commands["echo"])
+	}
+	if _, exists := config.Commands["deploy"]; !exists {
+		t.Error("Expected the commands of the later file to be added.")
+	}
+	if _, exists := config.Commands["list"]; !exists {
+		t.Error("Expected the commands of the first file to be kept.")
+	}
+
+	initFlags([]string{"-f", "-", "-f", "-"})
+	if _, err := mergeSourcefiles(Config{}); err == nil {
+		t.Error("Expected reading stdin twice to fail.")
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// forwardedSignals are passed on to a running command.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// setProcessGroup does nothing where process groups aren't available. The
+// returned function is called once the command has exited.
+func setProcessGroup(command *exec.Cmd) func() {
+	return func() {}
+}
+
+// signalProcessGroup passes a signal on to a command. Windows only supports
+// killing processes, and the console already sends Ctrl+C to the command.
+func signalProcessGroup(command *exec.Cmd, sig os.Signal) error {
+	if sig == os.Interrupt {
+		return nil
+	}
+	return command.Process.Kill()
+}
+
+// killProcessGroup kills a command.
+func killProcessGroup(command *exec.Cmd) error {
+	return command.Process.Kill()
+}
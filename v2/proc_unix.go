@@ -0,0 +1,69 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// forwardedSignals are passed on to the process group of a running command.
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+
+// setProcessGroup makes a command start in its own process group, so signals
+// reach everything it starts. When ahoy is in the foreground of a terminal,
+// the group is moved to the foreground instead, so the command can still read
+// from the terminal and gets Ctrl+C directly. The returned function gives the
+// terminal back to ahoy once the command has exited.
+func setProcessGroup(command *exec.Cmd) func() {
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if command.Stdin != os.Stdin || !isTerminal(os.Stdin) {
+		return func() {}
+	}
+	pgrp := syscall.Getpgrp()
+	if foreground, err := tcgetpgrp(os.Stdin.Fd()); err != nil || foreground != pgrp {
+		return func() {}
+	}
+	command.SysProcAttr.Foreground = true
+	command.SysProcAttr.Ctty = int(os.Stdin.Fd())
+	return func() {
+		// Processes in the background are stopped when they change the
+		// foreground group, unless they ignore SIGTTOU.
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+		tcsetpgrp(os.Stdin.Fd(), pgrp)
+	}
+}
+
+// signalProcessGroup sends a signal to every process in a command's group.
+func signalProcessGroup(command *exec.Cmd, sig os.Signal) error {
+	unixSignal, ok := sig.(syscall.Signal)
+	if !ok {
+		return command.Process.Signal(sig)
+	}
+	return syscall.Kill(-command.Process.Pid, unixSignal)
+}
+
+// killProcessGroup kills every process in a command's group.
+func killProcessGroup(command *exec.Cmd) error {
+	return syscall.Kill(-command.Process.Pid, syscall.SIGKILL)
+}
+
+func tcgetpgrp(fd uintptr) (int, error) {
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return 0, errno
+	}
+	return int(pgrp), nil
+}
+
+func tcsetpgrp(fd uintptr, pgrp int) error {
+	id := int32(pgrp)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&id))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// definition is where a command is written in an ahoy file.
+type definition struct {
+	file string
+	// line is 0 when it couldn't be found.
+	line int
+}
+
+func (d definition) String() string {
+	file := d.file
+	if file == stdinConfigFile {
+		file = "stdin"
+	}
+	if d.line > 0 {
+		return file + ":" + strconv.Itoa(d.line)
+	}
+	return file
+}
+
+// commandSource records where a command came from, for 'ahoy which' and the
+// help for the command.
+type commandSource struct {
+	definition
+	// overlays are the files merged over the definition, such as
+	// .ahoy.local.yml.
+	overlays []definition
+	// overrides are the other definitions of the command that this one
+	// replaced, such as the same command in an inherited or earlier imported
+	// file.
+	overrides []definition
+	// subcommands are the sources of the commands imported by this one.
+	subcommands map[string]*commandSource
+	// cmd is the command's config, after any overlays were merged.
+	cmd Command
+}
+
+func newCommandSource(cmd Command) *commandSource {
+	return &commandSource{definition: cmd.defined, overlays: cmd.overlays, cmd: cmd}
+}
+
+// override records that a command replaced another definition of itself.
+func (s *commandSource) override(other *commandSource) {
+	s.overrides = append(s.overrides, other.definition)
+	s.overrides = append(s.overrides, other.overrides...)
+}
+
+// commandError returns an error in the config of a command, saying where the
+// command is defined.
+func commandError(cmd Command, message string) error {
+	if cmd.defined.file != "" {
+		message += "\nDefined at " + cmd.defined.String() + "."
+	}
+	return configError(errors.New(message))
+}
+
+// commandSources are the sources of the app's commands, by name.
+var commandSources = map[string]*commandSource{}
+
+// findSource returns the source of the command at a path of names, along with
+// the sources of the commands that imported it, nearest first.
+func findSource(path []string) (*commandSource, []*commandSource) {
+	sources := commandSources
+	importedBy := []*commandSource{}
+	var source *commandSource
+	for _, name := range path {
+		if source != nil {
+			importedBy = append([]*commandSource{source}, importedBy...)
+		}
+		source = sources[name]
+		if source == nil {
+			return nil, nil
+		}
+		sources = source.subcommands
+	}
+	return source, importedBy
+}
+
+// sourceOfHelpName returns where the command shown in help came from, using
+// its help name, such as "ahoy docker up".
+func sourceOfHelpName(helpName string) string {
+	path := strings.Fields(helpName)
+	if len(path) < 2 {
+		return ""
+	}
+	if source, _ := findSource(path[1:]); source != nil {
+		return source.String()
+	}
+	return ""
+}
+
+var (
+	yamlKeyLine = regexp.MustCompile(`^(\s+)(?:"([^"]+)"|'([^']+)'|([^\s#'"][^#]*?))\s*:(\s|$)`)
+	jsonObject  = regexp.MustCompile(`"([^"]+)"\s*:\s*\{`)
+	tomlCommand = regexp.MustCompile(`^\s*\[\s*commands\.(?:"([^"]+)"|([^\s\].]+))\s*\]`)
+)
+
+// commandLines finds the line each command starts on in an ahoy file, so
+// errors and 'ahoy which' can point to it.
+func commandLines(file string, data []byte) map[string]int {
+	lines := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".toml":
+		for n := 1; scanner.Scan(); n++ {
+			if match := tomlCommand.FindStringSubmatch(scanner.Text()); match != nil {
+				lines[match[1]+match[2]] = n
+			}
+		}
+	case ".json":
+		inCommands := false
+		for n := 1; scanner.Scan(); n++ {
+			text := scanner.Text()
+			if !inCommands {
+				inCommands = strings.Contains(text, `"commands"`)
+				continue
+			}
+			for _, match := range jsonObject.FindAllStringSubmatch(text, -1) {
+				if _, found := lines[match[1]]; !found {
+					lines[match[1]] = n
+				}
+			}
+		}
+	default:
+		// Commands are the keys indented the least below 'commands:'.
+		inCommands, indent := false, ""
+		for n := 1; scanner.Scan(); n++ {
+			text := scanner.Text()
+			trimmed := strings.TrimSpace(text)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			if !strings.HasPrefix(text, " ") && !strings.HasPrefix(text, "\t") {
+				inCommands = strings.HasPrefix(text, "commands:")
+				indent = ""
+				continue
+			}
+			match := yamlKeyLine.FindStringSubmatch(text)
+			if !inCommands || match == nil {
+				continue
+			}
+			if indent == "" {
+				indent = match[1]
+			}
+			if match[1] == indent {
+				lines[match[2]+match[3]+match[4]] = n
+			}
+		}
+	}
+	return lines
+}
+
+func whichAction(c *cli.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return errors.New("Usage: ahoy which <command>")
+	}
+	command, rest := findCommand(c.App.Commands, args)
+	if command == nil || len(rest) > 0 {
+		return newAhoyError(exitCommandNotFound, errors.New("Command not found for '"+strings.Join(args, " ")+"'"))
+	}
+
+	// The path to the command uses the names of the commands found, rather
+	// than any aliases used.
+	path := []string{}
+	commands := c.App.Commands
+	for _, arg := range args {
+		for _, candidate := range commands {
+			if candidate.HasName(arg) {
+				path = append(path, candidate.Name)
+				commands = candidate.Subcommands
+				break
+			}
+		}
+	}
+	name := strings.Join(path, " ")
+	if given := strings.Join(args, " "); given != name {
+		fmt.Println("'" + given + "' is an alias of '" + name + "'.")
+	}
+	source, importedBy := findSource(path)
+	if source == nil {
+		fmt.Println("'" + name + "' is built into ahoy.")
+		return nil
+	}
+
+	fmt.Println(name)
+	if len(command.Aliases) > 0 {
+		printSourceLines("Aliases:", []string{strings.Join(command.Aliases, ", ")})
+	}
+	printSourceLines("Defined in:", []string{source.String()})
+	parents := []string{}
+	for i, parent := range importedBy {
+		parents = append(parents, path[len(path)-2-i]+" at "+parent.String())
+	}
+	printSourceLines("Imported by:", parents)
+	printSourceLines("Merged with:", definitionStrings(source.overlays))
+	printSourceLines("Overrides:", definitionStrings(source.overrides))
+	return nil
+}
+
+// printSourceLines prints a labelled list of values, one per line.
+func printSourceLines(label string, values []string) {
+	for i, value := range values {
+		if i > 0 {
+			label = ""
+		}
+		fmt.Printf("  %-13s%s\n", label, value)
+	}
+}
+
+func definitionStrings(definitions []definition) []string {
+	values := []string{}
+	for _, d := range definitions {
+		values = append(values, d.String())
+	}
+	return values
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommandLines(t *testing.T) {
+	tests := []struct {
+		file     string
+		data     string
+		expected map[string]int
+	}{
+		{".ahoy.yml", `ahoyapi: v2
+usage: Example
+# Comment.
+commands:
+  build:
+    usage: Build it
+    cmd: |
+      echo build:
+      echo done
+
+  "db:import":
+    cmd: ./import.sh
+  # Commented:
+  'test': {cmd: go test}
+env: .env
+`, map[string]int{"build": 5, "db:import": 11, "test": 14}},
+		{".ahoy.json", `{
+  "ahoyapi": "v2",
+  "commands": {
+    "build": {"cmd": "make"},
+    "test": {
+      "cmd": "go test"
+    }
+  }
+}`, map[string]int{"build": 4, "test": 5}},
+		{".ahoy.toml", `ahoyapi = "v2"
+
+[commands.build]
+cmd = "make"
+
+[commands."db:import"]
+cmd = "./import.sh"
+`, map[string]int{"build": 3, "db:import": 6}},
+	}
+	for _, test := range tests {
+		if lines := commandLines(test.file, []byte(test.data)); !reflect.DeepEqual(lines, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.file, test.expected, lines)
+		}
+	}
+}
+
+func TestWhich(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	main := write(".ahoy.yml", "ahoyapi: v2\ncommands:\n  build:\n    cmd: make\n  docker:\n    imports: [docker.ahoy.yml, more.ahoy.yml]\n")
+	docker := write("docker.ahoy.yml", "ahoyapi: v2\ncommands:\n  up:\n    cmd: echo one\n")
+	more := write("more.ahoy.yml", "ahoyapi: v2\ncommands:\n  down:\n    cmd: echo down\n  up:\n    aliases: [u]\n    cmd: echo two\n")
+	local := write(".ahoy.local.yml", "ahoyapi: v2\ncommands:\n  build:\n    usage: Build it locally\n")
+
+	out, _ := appRun([]string{"ahoy", "-f", main, "which", "docker", "u"})
+	for _, expected := range []string{
+		"'docker u' is an alias of 'docker up'.\n",
+		"  Defined in:  " + more + ":5\n",
+		"  Imported by: docker at " + main + ":5\n",
+		"  Overrides:   " + docker + ":3\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q, got:\n%s", expected, out)
+		}
+	}
+
+	out, _ = appRun([]string{"ahoy", "-f", main, "which", "build"})
+	if !strings.Contains(out, "  Defined in:  "+main+":3\n  Merged with: "+local+":3\n") {
+		t.Errorf("Expected the local overlay to be shown, got:\n%s", out)
+	}
+
+	out, _ = appRun([]string{"ahoy", "-f", main, "which", "doctor"})
+	if out != "'doctor' is built into ahoy.\n" {
+		t.Errorf("Expected built in commands to be shown as built in, got %q", out)
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	err := commandError(Command{defined: definition{file: ".ahoy.yml", line: 7}}, "Command [x] is broken.")
+	if code, _ := exitCode(err); code != exitConfigError || err.Error() != "Command [x] is broken.\nDefined at .ahoy.yml:7." {
+		t.Errorf("Expected a config error saying where the command is, got %d: %q", code, err)
+	}
+	if err := commandError(Command{}, "Command [x] is broken."); err.Error() != "Command [x] is broken." {
+		t.Errorf("Expected no location for commands that aren't from a file, got %q", err)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// remoteImportTimeout bounds how long we wait for a remote import to download.
+const remoteImportTimeout = 30 * time.Second
+
+// maxRemoteImportSize is the largest remote import or signature we download.
+const maxRemoteImportSize = 1 << 20
+
+// remoteImport describes an https import as it is stored in the cache.
+type remoteImport struct {
+	URL     string `json:"url"`
+	ETag    string `json:"etag,omitempty"`
+	SHA256  string `json:"sha256"`
+	Fetched string `json:"fetched"`
+
+	// previousSHA256 is the hash recorded before the import was downloaded
+	// again, so 'ahoy imports update' can report what changed.
+	previousSHA256 string
+}
+
+// importIntegrityError is returned when a remote import no longer matches
+// the content we expect. Unlike network failures, these are never ignored.
+type importIntegrityError struct {
+	msg string
+}
+
+func (e *importIntegrityError) Error() string {
+	return e.msg
+}
+
+// remoteImportsFetched records the remote imports loaded during this run so
+// that 'ahoy imports update' can report on them.
+var remoteImportsFetched []remoteImport
+
+// remoteImportClient downloads remote imports and their signatures. It only
+// follows redirects to other https URLs.
+var remoteImportClient = &http.Client{
+	Timeout:       remoteImportTimeout,
+	CheckRedirect: checkRemoteImportRedirect,
+}
+
+func checkRemoteImportRedirect(req *http.Request, via []*http.Request) error {
+	if req.URL.Scheme != "https" {
+		return &importIntegrityError{"Refusing import " + via[0].URL.String() + ": it redirects to " + req.URL.String() + ", but remote imports must use https."}
+	}
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// readRemoteBody reads a downloaded import or signature, failing when it's
+// larger than maxRemoteImportSize.
+func readRemoteBody(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxRemoteImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRemoteImportSize {
+		return nil, fmt.Errorf("it is larger than %d bytes", maxRemoteImportSize)
+	}
+	return data, nil
+}
+
+// isRemoteImport reports whether an import is a URL. Only https is supported,
+// so the content can't be replaced on the way.
+func isRemoteImport(include string) bool {
+	return strings.HasPrefix(include, "https://")
+}
+
+// parseRemoteImport splits an import such as
+// https://example.com/team.ahoy.yml#sha256:abc123 into its URL and pin.
+func parseRemoteImport(include string) (string, string) {
+	url, pin, found := strings.Cut(include, "#sha256:")
+	if !found {
+		return include, ""
+	}
+	return url, strings.ToLower(strings.TrimSpace(pin))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// remoteImportPaths returns the cached file and metadata paths for a URL.
+func remoteImportPaths(url string) (string, string, error) {
+	cacheDir, err := ahoyCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	key := sha256Hex([]byte(url))
+	dir := filepath.Join(cacheDir, "imports")
+	return filepath.Join(dir, key+".ahoy.yml"), filepath.Join(dir, key+".json"), nil
+}
+
+func readRemoteImportMeta(metaPath string) (remoteImport, bool) {
+	meta := remoteImport{}
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return meta, false
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, false
+	}
+	return meta, true
+}
+
+// checkRemoteImport verifies downloaded or cached content against the pin in
+// the import, or against the hash recorded when the URL was first imported.
+func checkRemoteImport(url string, pin string, sum string, meta remoteImport, hasMeta bool) error {
+	if pin != "" {
+		if sum != pin {
+			return &importIntegrityError{"Refusing import " + url + ": its sha256 is " + sum + " but the import is pinned to " + pin + "."}
+		}
+		return nil
+	}
+	if hasMeta && meta.SHA256 != "" && meta.SHA256 != sum && !AhoyConf.updateImports {
+		return &importIntegrityError{"Refusing import " + url + ": its content has changed since it was first imported. Run 'ahoy imports update' to accept the new version."}
+	}
+	return nil
+}
+
+// fetchRemoteImport downloads an https import into the cache, revalidating any
+// cached copy using its ETag, and returns the path of the cached file.
+func fetchRemoteImport(include string) (string, error) {
+	url, pin := parseRemoteImport(include)
+	filePath, metaPath, err := remoteImportPaths(url)
+	if err != nil {
+		return "", err
+	}
+	meta, hasMeta := readRemoteImportMeta(metaPath)
+	cached := hasMeta && fileExists(filePath)
+
+	useCache := func() (string, error) {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256Hex(data)
+		if sum != meta.SHA256 {
+			return "", &importIntegrityError{"Refusing import " + url + ": the cached copy at " + filePath + " has been modified."}
+		}
+		if err := checkRemoteImport(url, pin, sum, meta, false); err != nil {
+			return "", err
+		}
+		if !fileExists(filePath + signatureExt) {
+			fetchRemoteSignature(url, filePath)
+		}
+		meta.previousSHA256 = meta.SHA256
+		remoteImportsFetched = append(remoteImportsFetched, meta)
+		return filePath, nil
+	}
+
+	if AhoyConf.cachedImportsOnly {
+		if cached {
+			return useCache()
+		}
+		return "", fmt.Errorf("import %s hasn't been downloaded yet", url)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteImportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	if cached && meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+
+	resp, err := remoteImportClient.Do(req)
+	if err != nil {
+		var integrityErr *importIntegrityError
+		if errors.As(err, &integrityErr) {
+			return "", integrityErr
+		}
+		if cached {
+			if verbose {
+				log.Println("===> Ahoy could not reach", url, "- using cached copy:", err)
+			}
+			return useCache()
+		}
+		return "", fmt.Errorf("could not download import %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached {
+		return useCache()
+	}
+	if resp.StatusCode != http.StatusOK {
+		if cached {
+			if verbose {
+				log.Println("===> Ahoy got", resp.Status, "for", url, "- using cached copy")
+			}
+			return useCache()
+		}
+		return "", fmt.Errorf("could not download import %s: %s", url, resp.Status)
+	}
+
+	data, err := readRemoteBody(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("could not download import %s: %w", url, err)
+	}
+	sum := sha256Hex(data)
+	if err := checkRemoteImport(url, pin, sum, meta, hasMeta); err != nil {
+		return "", err
+	}
+
+	previous := ""
+	if hasMeta {
+		previous = meta.SHA256
+	}
+	meta = remoteImport{
+		URL:     url,
+		ETag:    resp.Header.Get("ETag"),
+		SHA256:  sum,
+		Fetched: time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		return "", err
+	}
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(metaPath, metaData, 0o644); err != nil {
+		return "", err
+	}
+	if verbose {
+		log.Println("===> Ahoy downloaded", url, "sha256:"+sum)
+	}
+	fetchRemoteSignature(url, filePath)
+	meta.previousSHA256 = previous
+	remoteImportsFetched = append(remoteImportsFetched, meta)
+	return filePath, nil
+}
+
+// fetchRemoteSignature downloads the detached signature for a remote import
+// next to its cached copy, when signatures need to be verified. A missing
+// signature is reported when the import is verified.
+func fetchRemoteSignature(url string, filePath string) {
+	if len(AhoyConf.trustedKeys) == 0 || AhoyConf.cachedImportsOnly {
+		return
+	}
+	os.Remove(filePath + signatureExt)
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteImportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+signatureExt, nil)
+	if err != nil {
+		return
+	}
+	resp, err := remoteImportClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	data, err := readRemoteBody(resp.Body)
+	if err != nil {
+		return
+	}
+	os.WriteFile(filePath+signatureExt, data, 0o644)
+}
+
+// updateImportsAction reports on the remote imports that were downloaded again
+// while loading the config for 'ahoy imports update', and which of them
+// changed.
+func updateImportsAction(c *cli.Context) error {
+	if len(remoteImportsFetched) == 0 {
+		fmt.Println("No remote imports found.")
+		return nil
+	}
+	for _, imported := range remoteImportsFetched {
+		switch imported.previousSHA256 {
+		case imported.SHA256:
+			fmt.Println(imported.URL + " is up to date (sha256:" + imported.SHA256 + ")")
+		case "":
+			fmt.Println("Downloaded " + imported.URL + " (sha256:" + imported.SHA256 + ")")
+		default:
+			fmt.Println("Updated " + imported.URL + " (sha256:" + imported.previousSHA256 + " -> sha256:" + imported.SHA256 + ")")
+		}
+	}
+	return nil
+}
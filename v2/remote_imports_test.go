@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const remoteYaml = `ahoyapi: v2
+commands:
+  remote-command:
+    usage: A command from a remote import.
+    cmd: echo "remote"
+`
+
+func remoteImportServer(t *testing.T, content *string, requests *int) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		etag := `"` + sha256Hex([]byte(*content)) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(*content))
+	}))
+	t.Cleanup(server.Close)
+	transport := remoteImportClient.Transport
+	remoteImportClient.Transport = server.Client().Transport
+	t.Cleanup(func() { remoteImportClient.Transport = transport })
+	return server
+}
+
+func TestParseRemoteImport(t *testing.T) {
+	url, pin := parseRemoteImport("https://example.com/team.ahoy.yml#sha256:ABC123")
+	if url != "https://example.com/team.ahoy.yml" || pin != "abc123" {
+		t.Errorf("Unexpected url %q and pin %q", url, pin)
+	}
+
+	url, pin = parseRemoteImport("https://example.com/team.ahoy.yml")
+	if url != "https://example.com/team.ahoy.yml" || pin != "" {
+		t.Errorf("Unexpected url %q and pin %q", url, pin)
+	}
+}
+
+func TestFetchRemoteImportCachesAndRevalidates(t *testing.T) {
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	AhoyConf.updateImports = false
+	content := remoteYaml
+	requests := 0
+	server := remoteImportServer(t, &content, &requests)
+
+	path, err := fetchRemoteImport(server.URL + "/team.ahoy.yml")
+	if err != nil {
+		t.Fatalf("Unexpected error fetching import: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != remoteYaml {
+		t.Errorf("Cached import does not match the served content: %q", string(data))
+	}
+
+	// The second fetch sends the ETag and is served from the cache.
+	cachedPath, err := fetchRemoteImport(server.URL + "/team.ahoy.yml")
+	if err != nil {
+		t.Fatalf("Unexpected error revalidating import: %v", err)
+	}
+	if cachedPath != path || requests != 2 {
+		t.Errorf("Expected the cached import to be revalidated, got %s after %d requests", cachedPath, requests)
+	}
+
+	// Once the server stops responding, the cached copy is still used.
+	server.Close()
+	if _, err := fetchRemoteImport(server.URL + "/team.ahoy.yml"); err != nil {
+		t.Errorf("Expected the cached import to be used offline, got: %v", err)
+	}
+}
+
+func TestFetchRemoteImportFromCacheOnly(t *testing.T) {
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	AhoyConf.updateImports = false
+	defer func() { AhoyConf.cachedImportsOnly = false }()
+	content := remoteYaml
+	requests := 0
+	server := remoteImportServer(t, &content, &requests)
+
+	path, err := fetchRemoteImport(server.URL + "/team.ahoy.yml")
+	if err != nil {
+		t.Fatalf("Unexpected error fetching import: %v", err)
+	}
+
+	// Shell completion uses the cached copy without revalidating it.
+	AhoyConf.cachedImportsOnly = true
+	if cachedPath, err := fetchRemoteImport(server.URL + "/team.ahoy.yml"); err != nil || cachedPath != path || requests != 1 {
+		t.Errorf("Expected the cached import to be used without a request, got %s after %d requests: %v", cachedPath, requests, err)
+	}
+	if _, err := fetchRemoteImport(server.URL + "/other.ahoy.yml"); err == nil || requests != 1 {
+		t.Errorf("Expected an import that isn't cached to fail without a request, got %d requests: %v", requests, err)
+	}
+}
+
+func TestFetchRemoteImportRefusesChangedContent(t *testing.T) {
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	AhoyConf.updateImports = false
+	defer func() { AhoyConf.updateImports = false }()
+	content := remoteYaml
+	requests := 0
+	server := remoteImportServer(t, &content, &requests)
+
+	if _, err := fetchRemoteImport(server.URL + "/team.ahoy.yml"); err != nil {
+		t.Fatalf("Unexpected error fetching import: %v", err)
+	}
+
+	content = remoteYaml + "    hide: true\n"
+	_, err := fetchRemoteImport(server.URL + "/team.ahoy.yml")
+	if _, ok := err.(*importIntegrityError); !ok {
+		t.Fatalf("Expected changed content to be refused, got: %v", err)
+	}
+
+	// 'ahoy imports update' accepts the new content.
+	AhoyConf.updateImports = true
+	path, err := fetchRemoteImport(server.URL + "/team.ahoy.yml")
+	if err != nil {
+		t.Fatalf("Expected the update to accept the new content, got: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != content {
+		t.Error("Expected the cache to contain the updated content.")
+	}
+}
+
+func TestFetchRemoteImportPinned(t *testing.T) {
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	content := remoteYaml
+	requests := 0
+	server := remoteImportServer(t, &content, &requests)
+
+	if _, err := fetchRemoteImport(server.URL + "/team.ahoy.yml#sha256:" + sha256Hex([]byte(remoteYaml))); err != nil {
+		t.Errorf("Expected a matching pin to be accepted, got: %v", err)
+	}
+
+	_, err := fetchRemoteImport(server.URL + "/other.ahoy.yml#sha256:" + sha256Hex([]byte("something else")))
+	if _, ok := err.(*importIntegrityError); !ok {
+		t.Errorf("Expected a mismatching pin to be refused, got: %v", err)
+	}
+}
+
+func TestGetSubCommandsRemoteImport(t *testing.T) {
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	AhoyConf.srcDir = ""
+	content := remoteYaml
+	requests := 0
+	server := remoteImportServer(t, &content, &requests)
+
+	actual, _ := getSubCommands([]string{
+		server.URL + "/team.ahoy.yml",
+		filepath.Join("testing", "bogus.ahoy.yml"),
+	})
+	if len(actual) != 1 || actual[0].Name != "remote-command" {
+		t.Errorf("Expected the remote import to provide remote-command, got: %v", actual)
+	}
+}
+
+func TestPlainHTTPImportsAreRefused(t *testing.T) {
+	if isRemoteImport("http://example.com/team.ahoy.yml") {
+		t.Error("Expected http imports not to be fetched")
+	}
+	_, err := resolveImport("http://example.com/team.ahoy.yml")
+	var integrityErr *importIntegrityError
+	if !errors.As(err, &integrityErr) || !strings.Contains(err.Error(), "must use https") {
+		t.Errorf("Expected http imports to be refused, got %v", err)
+	}
+}
+
+func TestRemoteImportRedirectsToHTTPAreRefused(t *testing.T) {
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	content := remoteYaml
+	requests := 0
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(remoteYaml))
+	}))
+	defer plain.Close()
+	server := remoteImportServer(t, &content, &requests)
+	server.Config.Handler = http.RedirectHandler(plain.URL+"/team.ahoy.yml", http.StatusFound)
+
+	_, err := fetchRemoteImport(server.URL + "/team.ahoy.yml")
+	var integrityErr *importIntegrityError
+	if !errors.As(err, &integrityErr) || !strings.Contains(err.Error(), "must use https") {
+		t.Errorf("Expected a redirect to http to be refused, got %v", err)
+	}
+}
+
+func TestRemoteImportSizeLimit(t *testing.T) {
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	content := remoteYaml + "#" + strings.Repeat("x", maxRemoteImportSize)
+	requests := 0
+	server := remoteImportServer(t, &content, &requests)
+
+	if _, err := fetchRemoteImport(server.URL + "/team.ahoy.yml"); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("Expected an import over the size limit to fail, got %v", err)
+	}
+}
+
+func TestImportsUpdateReportsChanges(t *testing.T) {
+	t.Setenv("AHOY_CACHE_DIR", t.TempDir())
+	content := remoteYaml
+	requests := 0
+	server := remoteImportServer(t, &content, &requests)
+	defer func(dir string) { AhoyConf.srcDir = dir }(AhoyConf.srcDir)
+	main := filepath.Join(t.TempDir(), ".ahoy.yml")
+	os.WriteFile(main, []byte("ahoyapi: v2\ncommands:\n  team:\n    imports: ["+server.URL+"/team.ahoy.yml]\n"), 0644)
+
+	out, _ := appRun([]string{"ahoy", "-f", main, "imports", "update"})
+	if !strings.Contains(out, "Downloaded "+server.URL+"/team.ahoy.yml") {
+		t.Errorf("Expected the first download to be reported, got %q", out)
+	}
+	out, _ = appRun([]string{"ahoy", "-f", main, "imports", "update"})
+	if !strings.Contains(out, server.URL+"/team.ahoy.yml is up to date") {
+		t.Errorf("Expected an unchanged import to be up to date, got %q", out)
+	}
+	content = remoteYaml + "    hide: true\n"
+	out, _ = appRun([]string{"ahoy", "-f", main, "imports", "update"})
+	if !strings.Contains(out, "Updated "+server.URL+"/team.ahoy.yml (sha256:"+sha256Hex([]byte(remoteYaml))+" -> sha256:"+sha256Hex([]byte(content))+")") {
+		t.Errorf("Expected the changed import to be reported, got %q", out)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Requirement is a tool that must be installed for commands to run, with an
+// optional version constraint. It can be written as a string, such as
+// "docker>=24", or as a map with a custom command to find the version:
+//
+//	requires:
+//	  - git
+//	  - docker>=24
+//	  - name: node
+//	    version: ^18
+//	    probe: node -p process.versions.node
+type Requirement struct {
+	Name    string
+	Version string
+	// Probe prints the installed version, and defaults to '<name> --version'.
+	Probe string
+}
+
+// UnmarshalYAML allows requirements to be written as a single string.
+func (r *Requirement) UnmarshalYAML(unmarshal func(any) error) error {
+	var text string
+	if err := unmarshal(&text); err == nil {
+		*r = parseRequirement(text)
+		return nil
+	}
+	type plain Requirement
+	if err := unmarshal((*plain)(r)); err != nil {
+		return err
+	}
+	if r.Name == "" {
+		return errors.New("a requirement must have a 'name'")
+	}
+	return nil
+}
+
+// parseRequirement splits a requirement such as "docker>=24" into the tool and
+// its version constraint.
+func parseRequirement(text string) Requirement {
+	text = strings.TrimSpace(text)
+	i := strings.IndexAny(text, "<>=!~^ ")
+	if i == -1 {
+		return Requirement{Name: text}
+	}
+	return Requirement{Name: text[:i], Version: strings.TrimSpace(text[i:])}
+}
+
+func (r Requirement) String() string {
+	return r.Name + r.Version
+}
+
+// probedVersion matches the first version number in a probe's output, such as
+// 24.0.7 in "Docker version 24.0.7, build afdd53b".
+var probedVersion = regexp.MustCompile(`\d+(\.\d+)*`)
+
+type requirementResult struct {
+	found string
+	err   error
+}
+
+// requirementResults caches checks for this run, as the same tool is usually
+// required by many commands.
+var requirementResults = map[Requirement]requirementResult{}
+
+// checkRequirement makes sure a required tool is installed, returning its
+// version (or path, if no version is needed). The probe is only run when
+// probe is set, as it comes from the ahoy file and may not be trusted yet.
+func checkRequirement(r Requirement, probe bool) (string, error) {
+	if result, found := requirementResults[r]; found {
+		return result.found, result.err
+	}
+	path, err := exec.LookPath(r.Name)
+	if err != nil {
+		return "", errors.New(r.Name + " was not found in your PATH")
+	}
+	if !probe || (r.Version == "" && r.Probe == "") {
+		return path, nil
+	}
+
+	found, err := probeRequirement(r)
+	requirementResults[r] = requirementResult{found, err}
+	return found, err
+}
+
+func probeRequirement(r Requirement) (string, error) {
+	probe := r.Probe
+	if probe == "" {
+		probe = r.Name + " --version"
+	}
+	output, err := exec.Command("bash", "-c", probe).CombinedOutput()
+	if err != nil {
+		return "", errors.New("'" + probe + "' failed: " + strings.TrimSpace(string(output)))
+	}
+	found := probedVersion.FindString(string(output))
+	if r.Version == "" {
+		return found, nil
+	}
+	if found == "" {
+		return "", errors.New("no version found in the output of '" + probe + "'")
+	}
+	v, err := parseSemver(found)
+	if err != nil {
+		// Versions with more than three parts, such as 1.2.3.4, are compared
+		// on the first three.
+		v, err = parseSemver(strings.Join(strings.SplitN(found, ".", 4)[:3], "."))
+		if err != nil {
+			return found, err
+		}
+	}
+	ok, err := matchesVersion(r.Version, v)
+	if err != nil {
+		return found, errors.New("invalid version constraint for " + r.Name + ": " + err.Error())
+	}
+	if !ok {
+		return found, errors.New(r.Name + " " + r.Version + " is required, but " + found + " is installed")
+	}
+	return found, nil
+}
+
+// checkRequirements checks all the requirements of a command before it runs.
+func checkRequirements(name string, requirements []Requirement) error {
+	failed := []string{}
+	for _, r := range requirements {
+		if _, err := checkRequirement(r, true); err != nil {
+			failed = append(failed, "  "+err.Error())
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.New("Command '" + name + "' is missing requirements:\n" + strings.Join(failed, "\n") + "\nRun 'ahoy doctor' to check everything your ahoy files need.")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestRequirementUnmarshal(t *testing.T) {
+	data := "requires:\n  - git\n  - docker>=24\n  - node ^18\n  - name: php\n    version: '>=8.1'\n    probe: php -r 'echo PHP_VERSION;'\n"
+	config := Config{}
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatalf("Failed to unmarshal requirements: %v", err)
+	}
+	expected := []Requirement{
+		{Name: "git"},
+		{Name: "docker", Version: ">=24"},
+		{Name: "node", Version: "^18"},
+		{Name: "php", Version: ">=8.1", Probe: "php -r 'echo PHP_VERSION;'"},
+	}
+	if !reflect.DeepEqual(config.Requires, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config.Requires)
+	}
+
+	if err := yaml.Unmarshal([]byte("requires:\n  - version: '>=1'\n"), &Config{}); err == nil {
+		t.Error("Expected a requirement without a name to be rejected")
+	}
+}
+
+func TestCheckRequirement(t *testing.T) {
+	requirementResults = map[Requirement]requirementResult{}
+
+	if _, err := checkRequirement(Requirement{Name: "ahoy-missing-tool"}, true); err == nil {
+		t.Error("Expected a missing tool to fail")
+	}
+
+	found, err := checkRequirement(Requirement{Name: "bash", Version: ">=1", Probe: "echo 'tool version 3.2.1, build 5'"}, true)
+	if err != nil || found != "3.2.1" {
+		t.Errorf("Expected version 3.2.1 to be found, got %q: %v", found, err)
+	}
+
+	_, err = checkRequirement(Requirement{Name: "bash", Version: ">=4", Probe: "echo 3.2.1"}, true)
+	if err == nil || !strings.Contains(err.Error(), "3.2.1 is installed") {
+		t.Errorf("Expected an old version to fail, got: %v", err)
+	}
+
+	// Probes aren't run for files that aren't trusted yet.
+	if _, err := checkRequirement(Requirement{Name: "bash", Version: ">=4", Probe: "exit 1"}, false); err != nil {
+		t.Errorf("Expected the probe to be skipped, got: %v", err)
+	}
+
+	err = checkRequirements("build", []Requirement{{Name: "bash"}, {Name: "ahoy-missing-tool"}})
+	if err == nil || !strings.Contains(err.Error(), "ahoy-missing-tool was not found") {
+		t.Errorf("Expected the missing tool to be reported, got: %v", err)
+	}
+}
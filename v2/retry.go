@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// defaultRetryDelay is how long ahoy waits before retrying a command, when
+// the command doesn't set a delay.
+const defaultRetryDelay = time.Second
+
+// Retry runs a command again when it fails, for commands that depend on the
+// network. It can be written as the number of attempts, or as a map:
+//
+//	retry:
+//	  attempts: 3
+//	  delay: 2s
+//	  backoff: 2
+//	  on_exit_codes: [1, 124]
+type Retry struct {
+	// Attempts is how many times the command runs at most, including the
+	// first time.
+	Attempts int
+	// Delay is how long to wait before the first retry, 1s by default.
+	Delay string
+	// Backoff multiplies the delay after each retry, so 2 doubles it.
+	Backoff float64
+	// OnExitCodes limits retries to failures with these exit codes.
+	OnExitCodes []int `yaml:"on_exit_codes"`
+}
+
+// UnmarshalYAML allows a retry to be written as just the number of attempts.
+func (r *Retry) UnmarshalYAML(unmarshal func(any) error) error {
+	var attempts int
+	if err := unmarshal(&attempts); err == nil {
+		*r = Retry{Attempts: attempts}
+		return nil
+	}
+	type plain Retry
+	return unmarshal((*plain)(r))
+}
+
+// retryPolicy is a command's Retry, checked when the command is loaded.
+type retryPolicy struct {
+	attempts    int
+	delay       time.Duration
+	backoff     float64
+	onExitCodes []int
+}
+
+// newRetryPolicy checks a command's Retry. Commands without one run once.
+func newRetryPolicy(retry *Retry) (retryPolicy, error) {
+	policy := retryPolicy{attempts: 1, delay: defaultRetryDelay, backoff: 1}
+	if retry == nil {
+		return policy, nil
+	}
+	if retry.Attempts < 1 {
+		return policy, errors.New("attempts must be at least 1")
+	}
+	policy.attempts = retry.Attempts
+	if retry.Delay != "" {
+		delay, err := time.ParseDuration(retry.Delay)
+		if err != nil || delay < 0 {
+			return policy, errors.New("the delay '" + retry.Delay + "' isn't a duration such as '5s'")
+		}
+		policy.delay = delay
+	}
+	if retry.Backoff != 0 {
+		if retry.Backoff < 1 {
+			return policy, errors.New("backoff must be 1 or more")
+		}
+		policy.backoff = retry.Backoff
+	}
+	policy.onExitCodes = retry.OnExitCodes
+	return policy, nil
+}
+
+// retries returns whether a command that failed with err and exit code should
+// run again. Commands are never retried when ahoy was interrupted, whatever
+// on_exit_codes lists. Commands that exit with 130, as when interrupted with
+// Ctrl+C, or 127, as when they couldn't be found, are only retried when the
+// code is listed in on_exit_codes.
+func (p retryPolicy) retries(err error, code int, attempt int) bool {
+	var interrupted *interruptedError
+	if err == nil || attempt >= p.attempts || errors.As(err, &interrupted) {
+		return false
+	}
+	if len(p.onExitCodes) > 0 {
+		return slices.Contains(p.onExitCodes, code)
+	}
+	return code != exitCommandNotFound && code != 128+int(syscall.SIGINT)
+}
+
+// delayBefore returns how long to wait before an attempt, growing by the
+// backoff after each retry.
+func (p retryPolicy) delayBefore(attempt int) time.Duration {
+	delay := float64(p.delay)
+	for i := 2; i < attempt; i++ {
+		delay *= p.backoff
+	}
+	return time.Duration(delay)
+}
+
+// waitToRetry logs a failed attempt and waits before the next one. It returns
+// false if ahoy is interrupted while waiting, or the command is being
+// restarted by 'ahoy watch', so the command isn't retried.
+func waitToRetry(name string, code int, attempt int, policy retryPolicy) bool {
+	delay := policy.delayBefore(attempt + 1)
+	logger("warn", "Attempt "+strconv.Itoa(attempt)+" of "+strconv.Itoa(policy.attempts)+" of '"+name+"' failed with exit code "+strconv.Itoa(code)+", retrying in "+delay.String()+".")
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-signals:
+		return false
+	case <-restartCommand:
+		return false
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+func TestRetryUnmarshal(t *testing.T) {
+	var config Config
+	data := `
+ahoyapi: v2
+commands:
+  short:
+    cmd: composer install
+    retry: 3
+  long:
+    cmd: docker pull nginx
+    retry:
+      attempts: 5
+      delay: 2s
+      backoff: 2
+      on_exit_codes: [1, 124]
+`
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	if retry := config.Commands["short"].Retry; retry == nil || retry.Attempts != 3 {
+		t.Errorf("Expected a number to set the attempts, got %+v", retry)
+	}
+	long := config.Commands["long"].Retry
+	if long == nil || long.Attempts != 5 || long.Delay != "2s" || long.Backoff != 2 || len(long.OnExitCodes) != 2 {
+		t.Errorf("Expected the retry map to be read, got %+v", long)
+	}
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	policy, err := newRetryPolicy(nil)
+	if err != nil || policy.attempts != 1 {
+		t.Errorf("Expected commands without a retry to run once, got %+v: %v", policy, err)
+	}
+
+	policy, err = newRetryPolicy(&Retry{Attempts: 4, Delay: "100ms", Backoff: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delays := []time.Duration{policy.delayBefore(2), policy.delayBefore(3), policy.delayBefore(4)}
+	expected := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond}
+	for i := range delays {
+		if delays[i] != expected[i] {
+			t.Errorf("Expected the delay before attempt %d to be %s, got %s", i+2, expected[i], delays[i])
+		}
+	}
+
+	invalid := []Retry{
+		{Attempts: 0},
+		{Attempts: 3, Delay: "soon"},
+		{Attempts: 3, Backoff: 0.5},
+	}
+	for _, retry := range invalid {
+		if _, err := newRetryPolicy(&retry); err == nil {
+			t.Errorf("Expected %+v to be invalid", retry)
+		}
+	}
+
+	config := Config{Commands: map[string]Command{"flaky": {Cmd: "true", Retry: &Retry{Attempts: -1}}}}
+	_, err = getCommands(config)
+	if code, _ := exitCode(err); code != exitConfigError {
+		t.Errorf("Expected an invalid retry to be a config error, got %d: %v", code, err)
+	}
+}
+
+func TestRetryPolicyRetries(t *testing.T) {
+	failed := errors.New("exit status 1")
+	policy := retryPolicy{attempts: 3}
+	tests := []struct {
+		name    string
+		policy  retryPolicy
+		err     error
+		code    int
+		attempt int
+		retries bool
+	}{
+		{"succeeded", policy, nil, 0, 1, false},
+		{"failed", policy, failed, 1, 1, true},
+		{"last attempt", policy, failed, 1, 3, false},
+		{"not found", policy, failed, exitCommandNotFound, 1, false},
+		{"ctrl+c", policy, failed, 130, 1, false},
+		{"ahoy interrupted", policy, &interruptedError{signal: syscall.SIGTERM, err: failed}, 1, 1, false},
+		{"listed code", retryPolicy{attempts: 3, onExitCodes: []int{2}}, failed, 2, 1, true},
+		{"unlisted code", retryPolicy{attempts: 3, onExitCodes: []int{2}}, failed, 1, 1, false},
+		{"listed ctrl+c", retryPolicy{attempts: 3, onExitCodes: []int{130, 127}}, failed, 130, 1, true},
+		{"listed not found", retryPolicy{attempts: 3, onExitCodes: []int{130, 127}}, failed, exitCommandNotFound, 1, true},
+		{"ahoy interrupted with its code listed", retryPolicy{attempts: 3, onExitCodes: []int{130}}, &interruptedError{signal: syscall.SIGINT, err: failed}, 130, 1, false},
+	}
+	for _, test := range tests {
+		if retries := test.policy.retries(test.err, test.code, test.attempt); retries != test.retries {
+			t.Errorf("%s: expected retries to be %v, got %v", test.name, test.retries, retries)
+		}
+	}
+}
+
+func TestRetryCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses bash to count attempts")
+	}
+	dir := t.TempDir()
+	count := filepath.Join(dir, "count")
+
+	// Fails twice, then succeeds.
+	flaky := `echo x >> "` + count + `"; [ "$(wc -l < "` + count + `")" -ge 3 ]`
+	config := Config{Entrypoint: []string{"bash", "-c", "{{cmd}}", "{{name}}"}, Commands: map[string]Command{
+		"flaky":  {Cmd: flaky, Retry: &Retry{Attempts: 3, Delay: "10ms"}},
+		"broken": {Cmd: "exit 5", Cleanup: `echo "$AHOY_EXIT_CODE" >> "` + count + `"`, Retry: &Retry{Attempts: 2, Delay: "10ms"}},
+	}}
+	commands, err := getCommands(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := cli.NewApp()
+	app.Commands = commands
+
+	if err := app.Run([]string{"ahoy", "flaky"}); err != nil {
+		t.Errorf("Expected the command to succeed on the third attempt, got: %v", err)
+	}
+	os.Remove(count)
+
+	err = app.Run([]string{"ahoy", "broken"})
+	if code, _ := exitCode(err); code != 5 {
+		t.Errorf("Expected the last attempt's exit code, got %d: %v", code, err)
+	}
+	data, _ := os.ReadFile(count)
+	if strings.TrimSpace(string(data)) != "5" {
+		t.Errorf("Expected the cleanup to run once after the last attempt, got %q", data)
+	}
+}
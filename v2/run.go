@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// defaultGracePeriod is how long a command has to exit after it's been sent a
+// signal, before it's killed.
+const defaultGracePeriod = 10 * time.Second
+
+// gracePeriod is set using --grace-period or AHOY_GRACE_PERIOD.
+var gracePeriod = defaultGracePeriod
+
+// restartCommand stops the running command so it can be run again, when a
+// file 'ahoy watch' is watching changes.
+var restartCommand chan os.Signal
+
+// commandTimeout is set using --timeout or AHOY_TIMEOUT, and replaces the
+// timeout set for the command.
+var commandTimeout time.Duration
+
+// runCommand runs a command in its own process group and waits for it to
+// exit. Signals ahoy receives are passed on to the whole group, and if it's
+// still running a grace period after the first signal, the group is killed.
+// A command that runs longer than its timeout is sent SIGTERM in the same way.
+// Failures after a signal was passed on are returned as an interruptedError.
+func runCommand(command *exec.Cmd, timeout time.Duration) error {
+	restoreTerminal := setProcessGroup(command)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := command.Start(); err != nil {
+		return err
+	}
+	defer restoreTerminal()
+	start := time.Now()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	timedOut := false
+	var interrupted os.Signal
+	done := make(chan error, 1)
+	go func() {
+		done <- command.Wait()
+	}()
+
+	var kill <-chan time.Time
+	for {
+		select {
+		case err := <-done:
+			if interrupted != nil && err != nil {
+				return &interruptedError{signal: interrupted, err: err}
+			}
+			if timedOut {
+				return &timeoutError{elapsed: time.Since(start)}
+			}
+			return err
+		case <-expired:
+			expired = nil
+			timedOut = true
+			signalProcessGroup(command, syscall.SIGTERM)
+			if kill == nil {
+				kill = time.After(gracePeriod)
+			}
+		case sig := <-restartCommand:
+			interrupted = sig
+			signalProcessGroup(command, sig)
+			if kill == nil {
+				kill = time.After(gracePeriod)
+			}
+		case sig := <-signals:
+			if verbose {
+				log.Println("===> Ahoy passing", sig, "on to the command")
+			}
+			interrupted = sig
+			signalProcessGroup(command, sig)
+			if kill == nil {
+				kill = time.After(gracePeriod)
+			}
+		case <-kill:
+			logger("warn", "The command didn't exit within "+gracePeriod.String()+", so it's being killed.")
+			killProcessGroup(command)
+		}
+	}
+}
+
+// runCleanup runs the cleanup for a command that failed or was interrupted,
+// in the same directory and environment, with the command's exit code in
+// AHOY_EXIT_CODE. A failed cleanup is reported, but doesn't change the exit
+// code ahoy exits with.
+func runCleanup(command *exec.Cmd, items []string, code int) {
+	cleanup := exec.Command(items[0], items[1:]...)
+	cleanup.Dir = command.Dir
+	cleanup.Stdout = os.Stdout
+	cleanup.Stdin = os.Stdin
+	cleanup.Stderr = os.Stderr
+	cleanup.Env = append(command.Env, "AHOY_EXIT_CODE="+strconv.Itoa(code))
+	if verbose {
+		log.Println("===> Ahoy cleaning up:", items)
+	}
+	if err := runCommand(cleanup, 0); err != nil {
+		logger("warn", "The cleanup failed: "+err.Error())
+	}
+}
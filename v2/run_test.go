@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// signalAhoy sends a signal to the test process after a delay, as if it was
+// sent to ahoy while a command is running.
+func signalAhoy(t *testing.T, sig os.Signal) {
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		process.Signal(sig)
+	}()
+}
+
+func TestRunCommandForwardsSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Signals can't be sent on Windows")
+	}
+
+	command := exec.Command("bash", "-c", "trap 'exit 3' TERM; sleep 30 & wait")
+	signalAhoy(t, syscall.SIGTERM)
+	err := runCommand(command, 0)
+	if code, _ := exitCode(commandExitStatus(err)); code != 3 {
+		t.Errorf("Expected the command to handle SIGTERM and exit with 3, got %d: %v", code, err)
+	}
+}
+
+func TestRunCommandGracePeriod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Signals can't be sent on Windows")
+	}
+	defer func(period time.Duration) { gracePeriod = period }(gracePeriod)
+	gracePeriod = 100 * time.Millisecond
+
+	command := exec.Command("bash", "-c", "trap '' TERM; sleep 30 & wait")
+	signalAhoy(t, syscall.SIGTERM)
+	start := time.Now()
+	err := runCommand(command, 0)
+	if code, _ := exitCode(commandExitStatus(err)); code != 137 {
+		t.Errorf("Expected the command to be killed, got %d: %v", code, err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("Expected the command to be killed after the grace period")
+	}
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses bash to run a slow command")
+	}
+
+	err := runCommand(exec.Command("bash", "-c", "sleep 30"), 100*time.Millisecond)
+	var timeoutErr *timeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.elapsed < 100*time.Millisecond {
+		t.Errorf("Expected the command to time out, got: %v", err)
+	}
+
+	if err := runCommand(exec.Command("bash", "-c", "true"), time.Minute); err != nil {
+		t.Errorf("Expected a quick command to finish before its timeout, got: %v", err)
+	}
+
+	// Invalid timeouts are config errors.
+	config := Config{Commands: map[string]Command{"slow": {Cmd: "sleep 1", Timeout: "soon"}}}
+	_, err = getCommands(config)
+	if code, _ := exitCode(err); code != exitConfigError {
+		t.Errorf("Expected an invalid timeout to be a config error, got %d: %v", code, err)
+	}
+}
+
+func TestRunCleanup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses bash for the cleanup")
+	}
+	dir := t.TempDir()
+	command := exec.Command("bash", "-c", "exit 4")
+	command.Dir = dir
+	command.Env = append(os.Environ(), "AHOY_TEST_VAR=kept")
+
+	items := entrypointCommand([]string{"bash", "-c", "{{cmd}}", "{{name}}"}, `echo "$AHOY_EXIT_CODE $AHOY_TEST_VAR $1" > cleanup.txt`, "build", []string{"arg"})
+	runCleanup(command, items, 4)
+
+	data, err := os.ReadFile(filepath.Join(dir, "cleanup.txt"))
+	if err != nil {
+		t.Fatalf("Expected the cleanup to run in the command's directory: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "4 kept arg" {
+		t.Errorf("Expected the exit code, environment and arguments to be passed to the cleanup, got %q", data)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ahoyVersionError is returned when an ahoy file needs a newer (or different)
+// version of ahoy than the one running. Like import integrity errors, these
+// are always reported, even for imports that would otherwise be skipped.
+type ahoyVersionError struct {
+	msg string
+}
+
+func (e *ahoyVersionError) Error() string {
+	return e.msg
+}
+
+// gitDescribeSuffix matches the commits since a tag that 'git describe' adds
+// to a version, as in 2.4.0-3-gabc1234 or 2.4.0-3-gabc1234-dirty.
+var gitDescribeSuffix = regexp.MustCompile(`-[0-9]+-g[0-9a-f]+(-dirty)?$`)
+
+// semver is a parsed version such as 2.4.0 or v2.4.0-rc.1. Versions given in
+// a constraint can leave out the minor or patch number. A 'git describe'
+// suffix is build metadata, so a development build counts as the release it
+// was built after rather than as a pre-release.
+type semver struct {
+	parts      [3]int
+	given      int
+	prerelease string
+}
+
+func parseSemver(text string) (semver, error) {
+	v := semver{}
+	text = strings.TrimPrefix(strings.TrimSpace(text), "v")
+	text, _, _ = strings.Cut(text, "+")
+	text = gitDescribeSuffix.ReplaceAllString(text, "")
+	text, v.prerelease, _ = strings.Cut(text, "-")
+	numbers := strings.Split(text, ".")
+	if text == "" || len(numbers) > 3 {
+		return v, errors.New("'" + text + "' is not a valid version")
+	}
+	for i, number := range numbers {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 0 {
+			return v, errors.New("'" + text + "' is not a valid version")
+		}
+		v.parts[i] = n
+	}
+	v.given = len(numbers)
+	return v, nil
+}
+
+// compare returns -1, 0 or 1 when v is lower, equal or higher than other.
+// Pre-releases are lower than the release they lead up to.
+func (v semver) compare(other semver) int {
+	for i := range v.parts {
+		if v.parts[i] != other.parts[i] {
+			if v.parts[i] < other.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	case v.prerelease < other.prerelease:
+		return -1
+	}
+	return 1
+}
+
+// upperBound returns the first version excluded by a ~ or ^ constraint.
+func (v semver) upperBound(op string) semver {
+	bound := semver{}
+	switch {
+	case op == "~" && v.given > 1:
+		bound.parts = [3]int{v.parts[0], v.parts[1] + 1, 0}
+	case op == "^" && v.parts[0] == 0 && v.given > 1:
+		bound.parts = [3]int{0, v.parts[1] + 1, 0}
+	default:
+		bound.parts = [3]int{v.parts[0] + 1, 0, 0}
+	}
+	return bound
+}
+
+var semverOperators = []string{">=", "<=", "!=", "==", ">", "<", "=", "~", "^"}
+
+// matchesVersion checks a version against a constraint such as ">=2.4.0",
+// ">=2.4, <3" or "^2.4". Every comma or space separated part must match.
+func matchesVersion(constraint string, v semver) (bool, error) {
+	fields := strings.FieldsFunc(constraint, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return false, errors.New("the version constraint is empty")
+	}
+	for i := 0; i < len(fields); i++ {
+		op := ""
+		for _, candidate := range semverOperators {
+			if strings.HasPrefix(fields[i], candidate) {
+				op = candidate
+				break
+			}
+		}
+		text := strings.TrimPrefix(fields[i], op)
+		// Allow a space between the operator and the version, as in ">= 2.4".
+		if text == "" && i+1 < len(fields) {
+			i++
+			text = fields[i]
+		}
+		required, err := parseSemver(text)
+		if err != nil {
+			return false, err
+		}
+		cmp := v.compare(required)
+		var ok bool
+		switch op {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "!=":
+			ok = cmp != 0
+		case "~", "^":
+			ok = cmp >= 0 && v.compare(required.upperBound(op)) < 0
+		default:
+			ok = cmp == 0
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// checkRequiredAhoy makes sure the running version of ahoy satisfies the
+// 'requires_ahoy' constraint of an ahoy file. Builds without a version set
+// using the linker, such as 'go run', aren't checked.
+func checkRequiredAhoy(file string, constraint string) error {
+	if constraint == "" || version == "" {
+		return nil
+	}
+	current, err := parseSemver(version)
+	if err != nil {
+		if verbose {
+			log.Println("===> Ahoy not checking requires_ahoy, the version", version, "isn't a release")
+		}
+		return nil
+	}
+	ok, err := matchesVersion(constraint, current)
+	if err != nil {
+		return errors.New("Invalid requires_ahoy '" + constraint + "' in " + file + ": " + err.Error())
+	}
+	if !ok {
+		return &ahoyVersionError{file + " requires ahoy " + constraint + ", but you are running " + version + ". Please upgrade ahoy, see https://github.com/ahoy-cli/ahoy#installation"}
+	}
+	return nil
+}
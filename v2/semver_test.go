@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMatchesVersion(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{">=2.4.0", "2.4.0", true},
+		{">=2.4.0", "v2.10.1", true},
+		{">=2.4.0", "2.3.9", false},
+		{">=2.4.0", "2.4.0-rc.1", false},
+		{">= 2.4", "2.4.1", true},
+		{">=2.4, <3", "3.0.0", false},
+		{">2.4.0 <3", "2.5.0", true},
+		{"^2.4", "2.9.0", true},
+		{"^2.4", "3.0.0", false},
+		{"^0.4.1", "0.5.0", false},
+		{"~2.4.1", "2.4.7", true},
+		{"~2.4.1", "2.5.0", false},
+		{"2.4.0", "2.4.0", true},
+		{"!=2.4.0", "2.4.0", false},
+		{">=2.4.0", "2.4.0-3-gabc1234", true},
+		{">=2.4.0", "v2.4.0-12-g0f9e8d7-dirty", true},
+		{">=2.4.0", "2.4.0-rc.1-3-gabc1234", false},
+		{"<2.4.0", "2.3.9-3-gabc1234", true},
+	}
+	for _, test := range tests {
+		v, err := parseSemver(test.version)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", test.version, err)
+		}
+		ok, err := matchesVersion(test.constraint, v)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.constraint, err)
+		}
+		if ok != test.expected {
+			t.Errorf("Expected %q matching %q to be %v", test.version, test.constraint, test.expected)
+		}
+	}
+
+	if _, err := matchesVersion(">=two", semver{}); err == nil {
+		t.Error("Expected an invalid constraint to be rejected")
+	}
+}
+
+func TestCheckRequiredAhoy(t *testing.T) {
+	defer func(v string) { version = v }(version)
+	data := []byte("ahoyapi: v2\nrequires_ahoy: \">=2.4.0\"\ncommands: {}\n")
+
+	version = ""
+	config := Config{}
+	if err := unmarshalVersionedConfig("new.ahoy.yml", data, &config); err != nil {
+		t.Errorf("Expected development builds to skip the check, got: %v", err)
+	}
+	if config.RequiresAhoy != ">=2.4.0" {
+		t.Errorf("Expected requires_ahoy to be loaded, got %q", config.RequiresAhoy)
+	}
+
+	version = "2.4.1"
+	if err := unmarshalVersionedConfig("new.ahoy.yml", data, &Config{}); err != nil {
+		t.Errorf("Expected 2.4.1 to satisfy >=2.4.0, got: %v", err)
+	}
+
+	// The version is checked before the rest of the file, so newer fields or
+	// API versions don't cause confusing errors.
+	version = "2.3.0"
+	data = []byte("ahoyapi: v9\nrequires_ahoy: \">=2.4.0\"\nnewer_field: true\n")
+	err := unmarshalVersionedConfig("new.ahoy.yml", data, &Config{})
+	var versionErr *ahoyVersionError
+	if !errors.As(err, &versionErr) || !strings.Contains(err.Error(), "upgrade") {
+		t.Errorf("Expected an upgrade message, got: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// signatureExt is appended to an imported file's path to find its detached signature.
+const signatureExt = ".sig"
+
+func parsePublicKey(key string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(data) != ed25519.PublicKeySize {
+		return nil, errors.New("'" + key + "' is not a valid base64 encoded ed25519 public key")
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// loadTrustedKeys collects the public keys that imported files must be signed
+// with, from the root config file and the user's trusted_keys file.
+func loadTrustedKeys(config Config) ([]ed25519.PublicKey, error) {
+	keys := []string{}
+	keys = append(keys, config.TrustedKeys...)
+	if dir, err := ahoyConfigDir(); err == nil {
+		// The trusted_keys file uses the same format as env files.
+		keys = append(keys, getEnvironmentVars(filepath.Join(dir, "trusted_keys"))...)
+	}
+
+	trustedKeys := []ed25519.PublicKey{}
+	for _, key := range keys {
+		publicKey, err := parsePublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		trustedKeys = append(trustedKeys, publicKey)
+	}
+	return trustedKeys, nil
+}
+
+// verifyImportSignature checks the detached signature of an imported file
+// against the trusted keys. Imports are only verified once keys are configured.
+// The source is the import as written in the config, used in error messages.
+func verifyImportSignature(file string, source string) error {
+	if len(AhoyConf.trustedKeys) == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	sigData, err := os.ReadFile(file + signatureExt)
+	if err != nil {
+		return errors.New("The import " + source + " is not signed, but trusted keys are configured. Sign it using 'ahoy sign'.")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return errors.New("The signature " + source + signatureExt + " is not a valid ed25519 signature.")
+	}
+	for _, key := range AhoyConf.trustedKeys {
+		if ed25519.Verify(key, data, signature) {
+			return nil
+		}
+	}
+	return errors.New("The signature for import " + source + " does not match any trusted key. Refusing to load it.")
+}
+
+// loadSigningKey reads a private key, generating and saving a new one if the
+// key file doesn't exist yet.
+func loadSigningKey(keyFile string) (ed25519.PrivateKey, bool, error) {
+	data, err := os.ReadFile(keyFile)
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != ed25519.PrivateKeySize {
+			return nil, false, errors.New("the signing key " + keyFile + " is not a valid ed25519 private key")
+		}
+		return ed25519.PrivateKey(key), false, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0o700); err != nil {
+		return nil, false, err
+	}
+	if err := os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
+
+// signFile writes a detached signature for file next to it.
+func signFile(file string, key ed25519.PrivateKey) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	return os.WriteFile(file+signatureExt, []byte(signature+"\n"), 0o644)
+}
+
+func signAction(c *cli.Context) error {
+	if len(c.Args()) == 0 {
+		return errors.New("Please specify the file to sign, e.g. 'ahoy sign shared.ahoy.yml'.")
+	}
+
+	keyFile := c.String("key")
+	if keyFile == "" {
+		dir, err := ahoyConfigDir()
+		if err != nil {
+			return err
+		}
+		keyFile = filepath.Join(dir, "signing.key")
+	}
+	key, generated, err := loadSigningKey(keyFile)
+	if err != nil {
+		return err
+	}
+	publicKey := base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	if generated {
+		fmt.Println("Generated a new signing key at " + keyFile + ".")
+	}
+
+	for _, file := range c.Args() {
+		if err := signFile(file, key); err != nil {
+			return err
+		}
+		fmt.Println("Signed " + file + ", signature written to " + file + signatureExt + ".")
+	}
+	fmt.Println("Add this public key to 'trusted_keys' to accept the signature: " + publicKey)
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const signedYaml = `ahoyapi: v2
+commands:
+  signed-command:
+    usage: A command from a signed import.
+    cmd: echo "signed"
+`
+
+func TestSignAndVerifyImport(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "shared.ahoy.yml")
+	if err := os.WriteFile(file, []byte(signedYaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	key, generated, err := loadSigningKey(filepath.Join(dir, "keys", "signing.key"))
+	if err != nil || !generated {
+		t.Fatalf("Expected a new signing key to be generated, got: %v", err)
+	}
+	if err := signFile(file, key); err != nil {
+		t.Fatalf("Unexpected error signing file: %v", err)
+	}
+
+	defer func() { AhoyConf.trustedKeys = nil }()
+	AhoyConf.trustedKeys = []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}
+	if err := verifyImportSignature(file, file); err != nil {
+		t.Errorf("Expected the signature to verify, got: %v", err)
+	}
+
+	// A signature by an untrusted key is refused.
+	otherKey, _, _ := loadSigningKey(filepath.Join(dir, "keys", "other.key"))
+	AhoyConf.trustedKeys = []ed25519.PublicKey{otherKey.Public().(ed25519.PublicKey)}
+	if err := verifyImportSignature(file, file); err == nil {
+		t.Error("Expected a signature from an untrusted key to be refused.")
+	}
+
+	// Modified content is refused.
+	AhoyConf.trustedKeys = []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}
+	os.WriteFile(file, []byte(signedYaml+"    hide: true\n"), 0o644)
+	if err := verifyImportSignature(file, file); err == nil {
+		t.Error("Expected a modified file to be refused.")
+	}
+
+	// Unsigned files are refused.
+	os.Remove(file + signatureExt)
+	if err := verifyImportSignature(file, file); err == nil {
+		t.Error("Expected an unsigned file to be refused.")
+	}
+
+	// Without trusted keys, nothing is verified.
+	AhoyConf.trustedKeys = nil
+	if err := verifyImportSignature(file, file); err != nil {
+		t.Errorf("Expected no verification without trusted keys, got: %v", err)
+	}
+}
+
+func TestLoadTrustedKeys(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+
+	publicKey, _, _ := ed25519.GenerateKey(nil)
+	userKey, _, _ := ed25519.GenerateKey(nil)
+	os.MkdirAll(filepath.Join(configDir, "ahoy"), 0o755)
+	os.WriteFile(filepath.Join(configDir, "ahoy", "trusted_keys"), []byte("# My keys\n"+base64.StdEncoding.EncodeToString(userKey)+"\n"), 0o644)
+
+	keys, err := loadTrustedKeys(Config{TrustedKeys: StringArray{base64.StdEncoding.EncodeToString(publicKey)}})
+	if err != nil {
+		t.Fatalf("Unexpected error loading trusted keys: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("Expected keys from the config and the user's trusted_keys file, got %d", len(keys))
+	}
+
+	if _, err := loadTrustedKeys(Config{TrustedKeys: StringArray{"bogus"}}); err == nil {
+		t.Error("Expected an invalid key to be rejected.")
+	}
+}
@@ -0,0 +1,41 @@
+#!/usr/bin/env bats
+
+setup() {
+  TEST_DIR="$(mktemp -d)"
+  export AHOY_CACHE_DIR="${TEST_DIR}/cache"
+
+  cat > "${TEST_DIR}/.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  now:
+    usage: Prints a different number every time it runs.
+    cmd: echo "\$RANDOM\$RANDOM"
+    cache: 1m
+EOF
+}
+
+teardown() {
+  rm -rf "${TEST_DIR}"
+}
+
+@test "A cached command's output is replayed" {
+  first="$(./ahoy -f "${TEST_DIR}/.ahoy.yml" now)"
+  second="$(./ahoy -f "${TEST_DIR}/.ahoy.yml" now)"
+  [ -n "$first" ]
+  [ "$first" = "$second" ]
+}
+
+@test "Different arguments are cached separately" {
+  first="$(./ahoy -f "${TEST_DIR}/.ahoy.yml" now a)"
+  second="$(./ahoy -f "${TEST_DIR}/.ahoy.yml" now b)"
+  [ "$first" != "$second" ]
+}
+
+@test "ahoy cache clear runs cached commands again" {
+  first="$(./ahoy -f "${TEST_DIR}/.ahoy.yml" now)"
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" cache clear
+  [ $status -eq 0 ]
+  [[ "$output" =~ "Removed 1 cached results." ]]
+  second="$(./ahoy -f "${TEST_DIR}/.ahoy.yml" now)"
+  [ "$first" != "$second" ]
+}
@@ -0,0 +1,69 @@
+#!/usr/bin/env bats
+
+setup() {
+  TEST_DIR="$(mktemp -d)"
+  mkdir "${TEST_DIR}/backups"
+  touch "${TEST_DIR}/backups/monday.sql"
+  cat > "${TEST_DIR}/.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  build:
+    usage: Build the app
+    aliases: [b]
+    cmd: make
+  secret:
+    hide: true
+    cmd: echo secret
+  db:import:
+    cmd: echo "\$1"
+    complete: ls backups
+  docker:
+    imports: [docker.ahoy.yml]
+EOF
+  cat > "${TEST_DIR}/docker.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  up:
+    usage: Start the containers
+    cmd: docker compose up
+EOF
+}
+
+teardown() {
+  rm -rf "${TEST_DIR}"
+}
+
+@test "ahoy completion prints a script for each shell" {
+  for shell in bash zsh fish powershell; do
+    run ./ahoy completion "$shell"
+    [ $status -eq 0 ]
+    [[ "$output" =~ "__complete" ]]
+  done
+}
+
+@test "ahoy completion fails for other shells" {
+  run ./ahoy completion tcsh
+  [ $status -ne 0 ]
+  [[ "$output" =~ "bash|zsh|fish|powershell" ]]
+}
+
+@test "Completion offers commands and aliases with their usage, but not hidden commands" {
+  run ./ahoy __complete -f "${TEST_DIR}/.ahoy.yml" b
+  [ $status -eq 0 ]
+  [[ "$output" =~ "build	Build the app" ]]
+  [[ "$output" =~ "b	Build the app" ]]
+  run ./ahoy __complete -f "${TEST_DIR}/.ahoy.yml" se
+  [[ ! "$output" =~ "secret" ]]
+}
+
+@test "Completion offers imported subcommands" {
+  run ./ahoy __complete -f "${TEST_DIR}/.ahoy.yml" docker ""
+  [ $status -eq 0 ]
+  [ "$output" = "up	Start the containers" ]
+}
+
+@test "Completion offers the output of a command's complete snippet" {
+  run ./ahoy __complete -f "${TEST_DIR}/.ahoy.yml" db:import ""
+  [ $status -eq 0 ]
+  [ "$output" = "monday.sql" ]
+}
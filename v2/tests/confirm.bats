@@ -0,0 +1,42 @@
+#!/usr/bin/env bats
+
+setup() {
+  TEST_DIR="$(mktemp -d)"
+  cat > "${TEST_DIR}/.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  reset:
+    usage: Asks before resetting.
+    cmd: echo "resetting \$1"
+    confirm: This will reset \$1. Continue?
+EOF
+}
+
+teardown() {
+  rm -rf "${TEST_DIR}"
+}
+
+@test "A command that needs confirming fails when ahoy can't ask" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" reset db < /dev/null
+  [ $status -eq 1 ]
+  [[ "$output" =~ "Command 'reset' needs to be confirmed" ]]
+  [[ ! "$output" =~ "resetting" ]]
+}
+
+@test "--yes runs a command without asking" {
+  run ./ahoy --yes -f "${TEST_DIR}/.ahoy.yml" reset db < /dev/null
+  [ $status -eq 0 ]
+  [[ "$output" =~ "resetting db" ]]
+}
+
+@test "AHOY_CONFIRM_RESPONSE answers the question" {
+  AHOY_CONFIRM_RESPONSE=y AHOY_CONFIRM_WAIT_SKIP=1 run ./ahoy -f "${TEST_DIR}/.ahoy.yml" reset db
+  [ $status -eq 0 ]
+  [[ "$output" =~ ">> This will reset db. Continue? [y/N] y" ]]
+  [[ "$output" =~ "resetting db" ]]
+
+  AHOY_CONFIRM_RESPONSE=n run ./ahoy -f "${TEST_DIR}/.ahoy.yml" reset db
+  [ $status -eq 0 ]
+  [[ "$output" =~ "The operation was canceled." ]]
+  [[ ! "$output" =~ "resetting" ]]
+}
@@ -0,0 +1,52 @@
+#!/usr/bin/env bats
+
+@test "A failing command's exit code is passed through" {
+  run ./ahoy -f testdata/exit-codes.ahoy.yml fail 3
+  [ $status -eq 3 ]
+}
+
+@test "A command killed by a signal exits with 128 plus the signal number" {
+  if [[ "$OSTYPE" == "msys" || "$OSTYPE" == "cygwin" ]]; then
+    skip "Signals aren't available on Windows"
+  fi
+  run ./ahoy -f testdata/exit-codes.ahoy.yml interrupted
+  [ $status -eq 130 ]
+}
+
+@test "An unknown command exits with 127" {
+  run ./ahoy -f testdata/exit-codes.ahoy.yml no-such-command
+  [ $status -eq 127 ]
+  [[ "$output" =~ "Command not found for 'no-such-command'" ]]
+}
+
+@test "A missing requirement exits with 69" {
+  run ./ahoy -f testdata/exit-codes.ahoy.yml needs-tool
+  [ $status -eq 69 ]
+  [[ "$output" =~ "ahoy-missing-tool was not found" ]]
+}
+
+@test "An invalid ahoy file exits with 78" {
+  run ./ahoy -f testdata/missing-cmd.ahoy.yml
+  [ $status -eq 78 ]
+}
+
+@test "The cleanup runs when a command fails, keeping its exit code" {
+  run ./ahoy -f testdata/exit-codes.ahoy.yml cleaned-up
+  [ $status -eq 5 ]
+  [[ "$output" =~ "cleaning up after 5" ]]
+}
+
+@test "A command that runs longer than its timeout exits with 124" {
+  run ./ahoy -f testdata/exit-codes.ahoy.yml hangs
+  [ $status -eq 124 ]
+  [[ "$output" =~ "Command 'hangs' timed out after" ]]
+  [[ "$output" =~ "cleaning up after 124" ]]
+}
+
+@test "--timeout applies to any command" {
+  run ./ahoy --timeout 1m -f testdata/exit-codes.ahoy.yml fail 0
+  [ $status -eq 0 ]
+  AHOY_TIMEOUT=100ms run ./ahoy -f testdata/exit-codes.ahoy.yml hangs
+  [ $status -eq 124 ]
+  [[ "$output" =~ "timed out after 1" ]]
+}
@@ -0,0 +1,41 @@
+#!/usr/bin/env bats
+
+setup() {
+  TEST_DIR="$(mktemp -d)"
+  cat > "${TEST_DIR}/.ahoy.yml" <<EOF
+ahoyapi: v2
+env: .env
+commands:
+  import:
+    usage: Imports a file.
+    cmd: touch "${TEST_DIR}/imported"
+EOF
+  echo "DB_PASS=hunter2" > "${TEST_DIR}/.env"
+}
+
+teardown() {
+  rm -rf "${TEST_DIR}"
+}
+
+@test "ahoy explain shows a command without running it" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" explain import dump.sql
+  [ $status -eq 0 ]
+  [[ "$output" =~ "Defined in: ${TEST_DIR}/.ahoy.yml" ]]
+  [[ "$output" =~ "[3] import" ]]
+  [[ "$output" =~ "[4] dump.sql" ]]
+  [[ "$output" =~ "DB_PASS=**** (.env)" ]]
+  [[ ! "$output" =~ "hunter2" ]]
+  [ ! -f "${TEST_DIR}/imported" ]
+}
+
+@test "--dry-run shows a command without running it" {
+  run ./ahoy --dry-run -f "${TEST_DIR}/.ahoy.yml" import
+  [ $status -eq 0 ]
+  [[ "$output" =~ "Command:    import" ]]
+  [ ! -f "${TEST_DIR}/imported" ]
+}
+
+@test "ahoy explain fails for commands that don't exist" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" explain missing
+  [ $status -eq 127 ]
+}
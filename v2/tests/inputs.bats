@@ -0,0 +1,59 @@
+#!/usr/bin/env bats
+
+setup() {
+  TEST_DIR="$(mktemp -d)"
+  cat > "${TEST_DIR}/.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  deploy:
+    usage: Asks where to deploy to.
+    cmd: echo "deploying \$1 to \$ENVIRONMENT, migrate=\$MIGRATE"
+    inputs:
+      - name: environment
+        type: select
+        choices: [staging, production]
+      - name: migrate
+        type: confirm
+        default: no
+  release:
+    cmd: echo "releasing \$1 as \$VERSION"
+    inputs:
+      - name: version
+        arg: 1
+EOF
+}
+
+teardown() {
+  rm -rf "${TEST_DIR}"
+}
+
+@test "Missing inputs are named when ahoy can't ask" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" deploy v1 < /dev/null
+  [ $status -eq 1 ]
+  [[ "$output" =~ "weren't given: environment." ]]
+  [[ ! "$output" =~ "deploying" ]]
+}
+
+@test "Inputs can be given as arguments, which aren't passed on" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" deploy --environment=production v1 --migrate < /dev/null
+  [ $status -eq 0 ]
+  [[ "$output" =~ "deploying v1 to production, migrate=true" ]]
+}
+
+@test "Inputs can be given in the environment" {
+  ENVIRONMENT=staging run ./ahoy -f "${TEST_DIR}/.ahoy.yml" deploy v1 < /dev/null
+  [ $status -eq 0 ]
+  [[ "$output" =~ "deploying v1 to staging, migrate=false" ]]
+}
+
+@test "An input that isn't one of the choices fails" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" deploy --environment=dev v1 < /dev/null
+  [ $status -eq 1 ]
+  [[ "$output" =~ "'dev' isn't one of the choices for environment: staging, production" ]]
+}
+
+@test "An input with an arg takes the argument at that position" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" release 1.2.0 < /dev/null
+  [ $status -eq 0 ]
+  [[ "$output" =~ "releasing 1.2.0 as 1.2.0" ]]
+}
@@ -0,0 +1,47 @@
+#!/usr/bin/env bats
+
+setup() {
+  TEST_DIR="$(mktemp -d)"
+  cat > "${TEST_DIR}/.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  build:
+    usage: Build the app
+    cmd: make
+  docker:
+    usage: Docker commands
+    imports: [docker.ahoy.yml]
+EOF
+  cat > "${TEST_DIR}/docker.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  up:
+    usage: Start the containers
+    cmd: docker compose up
+EOF
+}
+
+teardown() {
+  rm -rf "${TEST_DIR}"
+}
+
+@test "ahoy list shows commands and subcommands as a tree" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" list
+  [ $status -eq 0 ]
+  [[ "$output" =~ "build" ]]
+  [[ "$output" =~ "└── up" ]]
+  [[ "$output" =~ "Start the containers" ]]
+}
+
+@test "ahoy list --json lists commands with their sources" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" list --json
+  [ $status -eq 0 ]
+  [[ "$output" =~ "\"path\": \"docker up\"" ]]
+  [[ "$output" =~ "\"file\": \"${TEST_DIR}/docker.ahoy.yml\"" ]]
+}
+
+@test "ahoy list --yaml lists commands as YAML" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" list --yaml
+  [ $status -eq 0 ]
+  [[ "$output" =~ "- name: build" ]]
+}
@@ -0,0 +1,43 @@
+#!/usr/bin/env bats
+
+setup() {
+  TEST_DIR="$(mktemp -d)"
+  cat > "${TEST_DIR}/.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  db:import:
+    usage: Holds the db lock for a while.
+    cmd: echo "importing"; sleep 2; echo "imported"
+    lock: db
+  db:reset:
+    usage: Shares the db lock.
+    cmd: echo "resetting"
+    lock: db
+EOF
+}
+
+teardown() {
+  rm -rf "${TEST_DIR}"
+}
+
+@test "Commands sharing a lock wait for each other" {
+  ./ahoy -f "${TEST_DIR}/.ahoy.yml" db:import > "${TEST_DIR}/import.log" &
+  sleep 0.5
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" db:reset
+  wait
+  [ $status -eq 0 ]
+  [[ "$output" =~ "Waiting for the lock 'db', which is held by PID" ]]
+  [[ "$output" =~ "running 'db:import'" ]]
+  [[ "$output" =~ "resetting" ]]
+  [ -f "${TEST_DIR}/.ahoy/.gitignore" ]
+}
+
+@test "--no-wait fails with 75 when the lock is held" {
+  ./ahoy -f "${TEST_DIR}/.ahoy.yml" db:import > "${TEST_DIR}/import.log" &
+  sleep 0.5
+  run ./ahoy --no-wait -f "${TEST_DIR}/.ahoy.yml" db:reset
+  wait
+  [ $status -eq 75 ]
+  [[ "$output" =~ "Command 'db:reset' can't run, as the lock 'db' is held by PID" ]]
+  [[ ! "$output" =~ "resetting" ]]
+}
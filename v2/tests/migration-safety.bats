@@ -143,9 +143,9 @@ bats_require_minimum_version 1.5.0
 }
 
 @test "API version enforcement is preserved" {
-  # Test that ahoyapi: v2 requirement is still enforced
+  # Test that unsupported API versions are still rejected
   cat > /tmp/old-api.yml << 'EOF'
-ahoyapi: v1
+ahoyapi: v0
 commands:
   test:
     cmd: echo "old api"
@@ -158,6 +158,32 @@ EOF
   rm -f /tmp/old-api.yml
 }
 
+@test "Files written for the v1 API still load" {
+  run ./ahoy -f testdata/v1.ahoy.yml hello world
+  [ $status -eq 0 ]
+  [ "$output" == "hello world" ]
+}
+
+@test "ahoy migrate --dry-run shows the changes without writing them" {
+  cp testdata/v1.ahoy.yml /tmp/migrate.ahoy.yml
+  run ./ahoy migrate --dry-run /tmp/migrate.ahoy.yml
+  [ $status -eq 0 ]
+  [[ "$output" == *"+ahoyapi: v2"* ]]
+  grep -q "ahoyapi: v1" /tmp/migrate.ahoy.yml
+  rm -f /tmp/migrate.ahoy.yml
+}
+
+@test "ahoy migrate keeps v2 files at v2 unless --to v3 is given" {
+  cp testdata/simple.ahoy.yml /tmp/migrate.ahoy.yml
+  run ./ahoy migrate /tmp/migrate.ahoy.yml
+  [ $status -eq 0 ]
+  [[ "$output" == *"already uses ahoyapi v2 or later"* ]]
+  run ./ahoy migrate --to v3 /tmp/migrate.ahoy.yml
+  [ $status -eq 0 ]
+  grep -q "ahoyapi: v3" /tmp/migrate.ahoy.yml
+  rm -f /tmp/migrate.ahoy.yml
+}
+
 @test "Working directory behavior is preserved" {
   # Simplified test - verify that commands execute from the correct directory
   run ./ahoy -f testdata/simple.ahoy.yml echo "working directory test"
@@ -21,7 +21,7 @@ load 'test_helpers/bats-assert/load'
 
   # Try to run the optional command (it should fail gracefully)
   run ./ahoy -f testdata/optional-command.ahoy.yml optional-cmd
-  [ $status -eq 1 ]
+  [ $status -eq 127 ]
   [[ "$output" =~ "Command not found for 'optional-cmd'" ]]
 
   # Run the regular command (it should work)
@@ -34,8 +34,8 @@ load 'test_helpers/bats-assert/load'
   # Run ahoy without arguments
   run ./ahoy -f testdata/non-optional-command.ahoy.yml
 
-  # Check that the command failed
-  [ $status -eq 1 ]
+  # Check that the command failed with a config error
+  [ $status -eq 78 ]
 
   # Check for the appropriate error message
   [[ "$output" =~ "Command [non-optional-cmd] has 'imports' set, but no commands were found" ]]
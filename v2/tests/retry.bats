@@ -0,0 +1,25 @@
+#!/usr/bin/env bats
+
+setup() {
+  COUNT="$(mktemp -d)/count"
+}
+
+@test "A failing command is retried until it succeeds" {
+  run ./ahoy -f testdata/retry.ahoy.yml flaky "$COUNT"
+  [ $status -eq 0 ]
+  [ "$(wc -l < "$COUNT")" -eq 3 ]
+  [[ "$output" =~ "Attempt 1 of 3 of 'flaky' failed with exit code 1, retrying in 10ms." ]]
+  [[ "$output" =~ "Attempt 2 of 3 of 'flaky' failed with exit code 1, retrying in 10ms." ]]
+}
+
+@test "The last attempt's exit code is kept, and the cleanup runs once" {
+  run ./ahoy -f testdata/retry.ahoy.yml always-fails
+  [ $status -eq 4 ]
+  [ "$(echo "$output" | grep -c "cleaning up after 4")" -eq 1 ]
+}
+
+@test "Only the exit codes in on_exit_codes are retried" {
+  run ./ahoy -f testdata/retry.ahoy.yml only-on-code "$COUNT"
+  [ $status -eq 3 ]
+  [ "$(wc -l < "$COUNT")" -eq 1 ]
+}
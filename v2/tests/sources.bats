@@ -0,0 +1,54 @@
+#!/usr/bin/env bats
+
+setup() {
+  TEST_DIR="$(mktemp -d)"
+  mkdir -p "${TEST_DIR}/src"
+  echo "body { color: red; }" > "${TEST_DIR}/src/style.css"
+
+  cat > "${TEST_DIR}/.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  build:
+    usage: Builds the assets.
+    cmd: mkdir -p dist && cat src/*.css > dist/style.css && echo "building"
+    sources:
+      - src/**/*.css
+    generates:
+      - dist/style.css
+EOF
+}
+
+teardown() {
+  rm -rf "${TEST_DIR}"
+}
+
+@test "A command is skipped when its sources haven't changed" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" build
+  [ $status -eq 0 ]
+  [[ "$output" =~ "building" ]]
+
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" build
+  [ $status -eq 0 ]
+  [[ "$output" =~ "Command 'build' is up to date" ]]
+  [[ ! "$output" =~ "building" ]]
+  [ -f "${TEST_DIR}/.ahoy/.gitignore" ]
+}
+
+@test "A command runs again when a source changes or a generated file is missing" {
+  ./ahoy -f "${TEST_DIR}/.ahoy.yml" build
+
+  echo "body { color: blue; }" > "${TEST_DIR}/src/style.css"
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" build
+  [[ "$output" =~ "building" ]]
+
+  rm "${TEST_DIR}/dist/style.css"
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" build
+  [[ "$output" =~ "building" ]]
+}
+
+@test "--force runs a command that is up to date" {
+  ./ahoy -f "${TEST_DIR}/.ahoy.yml" build
+  run ./ahoy --force -f "${TEST_DIR}/.ahoy.yml" build
+  [ $status -eq 0 ]
+  [[ "$output" =~ "building" ]]
+}
@@ -96,8 +96,8 @@ EOF
   run ./ahoy -f empty.ahoy.yml
   [[ "$output" =~ "empty-import" ]]
 
-  # Check that the command executed with an expected error
-  [ "$status" -eq 1 ]
+  # Check that the command executed with a config error
+  [ "$status" -eq 78 ]
 
   run ./ahoy -f empty.ahoy.yml empty-import
   
@@ -105,6 +105,6 @@ EOF
   
   [[ "$output" =~ "but it is empty" ]]
   
-  # Check that the command executed with an expected error
-  [ "$status" -eq 1 ]
+  # Check that the command executed with a config error
+  [ "$status" -eq 78 ]
 }
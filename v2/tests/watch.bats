@@ -0,0 +1,57 @@
+#!/usr/bin/env bats
+
+setup() {
+  TEST_DIR="$(mktemp -d)"
+  mkdir -p "${TEST_DIR}/src"
+  echo "one" > "${TEST_DIR}/src/input.txt"
+
+  cat > "${TEST_DIR}/.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  build:
+    usage: Prints its input.
+    cmd: cat src/input.txt
+    watch:
+      - src/**
+      - "!src/*.log"
+  unwatched:
+    usage: Has nothing to watch.
+    cmd: echo "unwatched"
+EOF
+}
+
+teardown() {
+  rm -rf "${TEST_DIR}"
+}
+
+@test "ahoy watch runs a command again when its files change" {
+  if [[ "$OSTYPE" == "msys" || "$OSTYPE" == "cygwin" ]]; then
+    skip "Signals aren't available on Windows"
+  fi
+  ./ahoy -f "${TEST_DIR}/.ahoy.yml" watch --interval 50ms build > "${TEST_DIR}/output" 2>&1 &
+  pid=$!
+  sleep 1
+  echo "ignored" > "${TEST_DIR}/src/debug.log"
+  sleep 1
+  echo "two" > "${TEST_DIR}/src/input.txt"
+  sleep 1
+  kill -INT $pid
+  wait $pid || true
+
+  run cat "${TEST_DIR}/output"
+  [[ "$output" =~ "one" ]]
+  [[ "$output" =~ "src/input.txt changed, running 'build' again." ]]
+  [[ "$output" =~ "two" ]]
+  [[ ! "$output" =~ "debug.log" ]]
+}
+
+@test "ahoy watch needs 'watch' or 'sources' files" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" watch unwatched
+  [ $status -eq 1 ]
+  [[ "$output" =~ "Command 'unwatched' has no 'watch' or 'sources' files to watch." ]]
+}
+
+@test "ahoy watch fails for unknown commands" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" watch no-such-command
+  [ $status -eq 127 ]
+}
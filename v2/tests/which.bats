@@ -0,0 +1,43 @@
+#!/usr/bin/env bats
+
+setup() {
+  TEST_DIR="$(mktemp -d)"
+  cat > "${TEST_DIR}/.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  build:
+    aliases: [b]
+    cmd: make
+  docker:
+    imports: [docker.ahoy.yml]
+EOF
+  cat > "${TEST_DIR}/docker.ahoy.yml" <<EOF
+ahoyapi: v2
+commands:
+  up:
+    cmd: docker compose up
+EOF
+}
+
+teardown() {
+  rm -rf "${TEST_DIR}"
+}
+
+@test "ahoy which shows where a command is defined" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" which b
+  [ $status -eq 0 ]
+  [[ "$output" =~ "'b' is an alias of 'build'." ]]
+  [[ "$output" =~ "Defined in:  ${TEST_DIR}/.ahoy.yml:3" ]]
+}
+
+@test "ahoy which shows the commands that imported a subcommand" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" which docker up
+  [ $status -eq 0 ]
+  [[ "$output" =~ "Defined in:  ${TEST_DIR}/docker.ahoy.yml:3" ]]
+  [[ "$output" =~ "Imported by: docker at ${TEST_DIR}/.ahoy.yml:6" ]]
+}
+
+@test "ahoy which fails for commands that don't exist" {
+  run ./ahoy -f "${TEST_DIR}/.ahoy.yml" which missing
+  [ $status -eq 127 ]
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// trustStore maps the absolute path of each trusted ahoy file to the sha256
+// of its content when it was trusted.
+type trustStore map[string]string
+
+func trustStorePath() (string, error) {
+	dir, err := ahoyStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "trusted.json"), nil
+}
+
+func loadTrustStore() trustStore {
+	store := trustStore{}
+	path, err := trustStorePath()
+	if err != nil {
+		return store
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return store
+	}
+	json.Unmarshal(data, &store)
+	return store
+}
+
+func (store trustStore) save() error {
+	path, err := trustStorePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// trackConfigFile records a local ahoy file that was loaded, so that it has to
+// be trusted before any of its commands are run.
+func trackConfigFile(file string) {
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	for _, tracked := range AhoyConf.configFiles {
+		if tracked == file {
+			return
+		}
+	}
+	AhoyConf.configFiles = append(AhoyConf.configFiles, file)
+}
+
+// untrustedFiles returns the loaded files that are new or have changed since
+// they were trusted, along with a description of why.
+func untrustedFiles(store trustStore) ([]string, map[string]string) {
+	files := []string{}
+	reasons := map[string]string{}
+	for _, file := range AhoyConf.configFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		hash, trusted := store[file]
+		if trusted && hash == sha256Hex(data) {
+			continue
+		}
+		files = append(files, file)
+		if trusted {
+			reasons[file] = "modified"
+		} else {
+			reasons[file] = "new"
+		}
+	}
+	return files, reasons
+}
+
+// trustFiles records the current content of the given files as trusted.
+func trustFiles(store trustStore, files []string) error {
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		store[file] = sha256Hex(data)
+	}
+	return store.save()
+}
+
+// printTrustSummary describes the untrusted files and the commands they define.
+func printTrustSummary(files []string, reasons map[string]string) {
+	fmt.Fprintln(os.Stderr, "The following ahoy files are new or have changed since you last trusted them:")
+	for _, file := range files {
+		fmt.Fprintf(os.Stderr, "  %s (%s)\n", file, reasons[file])
+		config, err := getConfig(file)
+		if err != nil {
+			continue
+		}
+		var names []string
+		for name := range config.Commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) > 0 {
+			fmt.Fprintf(os.Stderr, "    commands: %s\n", strings.Join(names, ", "))
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Ahoy files can run any command on your machine, so check them before trusting them.")
+}
+
+// filesTrusted reports whether every loaded ahoy file is trusted, without
+// asking, for running snippets from them in the background.
+func filesTrusted() bool {
+	if os.Getenv("AHOY_TRUST_ALL") != "" {
+		return true
+	}
+	files, _ := untrustedFiles(loadTrustStore())
+	return len(files) == 0
+}
+
+// ensureTrusted makes sure every loaded ahoy file has been trusted before a
+// command from them is run, prompting the user when running interactively.
+// Set AHOY_TRUST_ALL to skip the check, for example in CI.
+func ensureTrusted(name string) error {
+	if os.Getenv("AHOY_TRUST_ALL") != "" {
+		return nil
+	}
+	store := loadTrustStore()
+	files, reasons := untrustedFiles(store)
+	if len(files) == 0 {
+		return nil
+	}
+
+	printTrustSummary(files, reasons)
+	if !isTerminal(os.Stdin) {
+		return errors.New("Refusing to run '" + name + "' from untrusted ahoy files. Run 'ahoy trust' after checking them, or set AHOY_TRUST_ALL=1 to skip this check.")
+	}
+
+	fmt.Fprint(os.Stderr, "Do you trust these files and want to run '"+name+"', y/N ? ")
+	reader := bufio.NewReader(os.Stdin)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer != "y" && answer != "Y" {
+		return errors.New("Not running '" + name + "' from untrusted ahoy files. Run 'ahoy trust' to trust them.")
+	}
+	return trustFiles(store, files)
+}
+
+func trustAction(c *cli.Context) error {
+	store := loadTrustStore()
+	if len(AhoyConf.configFiles) == 0 {
+		return errors.New("No .ahoy.yml found to trust.")
+	}
+
+	if c.Bool("revoke") {
+		for _, file := range AhoyConf.configFiles {
+			delete(store, file)
+		}
+		if err := store.save(); err != nil {
+			return err
+		}
+		fmt.Println("No longer trusting:")
+	} else {
+		if err := trustFiles(store, AhoyConf.configFiles); err != nil {
+			return err
+		}
+		fmt.Println("Trusted:")
+	}
+	for _, file := range AhoyConf.configFiles {
+		fmt.Println("  " + file)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureTrusted(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("AHOY_TRUST_ALL", "")
+	originalFiles := AhoyConf.configFiles
+	defer func() { AhoyConf.configFiles = originalFiles }()
+
+	file := filepath.Join(t.TempDir(), ".ahoy.yml")
+	os.WriteFile(file, []byte("ahoyapi: v2\ncommands:\n  hello:\n    cmd: echo hello\n"), 0o644)
+	AhoyConf.configFiles = nil
+	trackConfigFile(file)
+
+	// New files are refused when not running in a terminal.
+	if err := ensureTrusted("hello"); err == nil {
+		t.Fatal("Expected a new file to be refused.")
+	}
+
+	store := loadTrustStore()
+	if err := trustFiles(store, AhoyConf.configFiles); err != nil {
+		t.Fatalf("Unexpected error trusting files: %v", err)
+	}
+	if err := ensureTrusted("hello"); err != nil {
+		t.Errorf("Expected a trusted file to be accepted, got: %v", err)
+	}
+
+	// Modifying the file means it has to be trusted again.
+	os.WriteFile(file, []byte("ahoyapi: v2\ncommands:\n  hello:\n    cmd: rm -rf /\n"), 0o644)
+	files, reasons := untrustedFiles(loadTrustStore())
+	if len(files) != 1 || reasons[file] != "modified" {
+		t.Errorf("Expected the file to be reported as modified, got: %v", reasons)
+	}
+	if err := ensureTrusted("hello"); err == nil {
+		t.Error("Expected a modified file to be refused.")
+	}
+
+	// AHOY_TRUST_ALL skips the check.
+	t.Setenv("AHOY_TRUST_ALL", "1")
+	if err := ensureTrusted("hello"); err != nil {
+		t.Errorf("Expected AHOY_TRUST_ALL to skip the check, got: %v", err)
+	}
+}
+
+func TestTrackConfigFile(t *testing.T) {
+	originalFiles := AhoyConf.configFiles
+	defer func() { AhoyConf.configFiles = originalFiles }()
+	AhoyConf.configFiles = nil
+
+	trackConfigFile("testdata/simple.ahoy.yml")
+	trackConfigFile("testdata/simple.ahoy.yml")
+	if len(AhoyConf.configFiles) != 1 || !filepath.IsAbs(AhoyConf.configFiles[0]) {
+		t.Errorf("Expected one absolute path to be tracked, got: %v", AhoyConf.configFiles)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli"
+)
+
+// userCommandsCategory groups the user's own commands in the help output.
+const userCommandsCategory = "User commands"
+
+// getUserConfig loads the user's own ahoy file from ~/.config/ahoy/ahoy.yml,
+// if there is one. Its commands are available in every directory.
+func getUserConfig() (string, Config, error) {
+	dir, err := ahoyConfigDir()
+	if err != nil {
+		return "", Config{}, nil
+	}
+	file := filepath.Join(dir, "ahoy.yml")
+	if !fileExists(file) {
+		return "", Config{}, nil
+	}
+	config, err := getConfig(file)
+	if err != nil {
+		return "", config, err
+	}
+	return file, config, nil
+}
+
+// getUserCommands builds the commands from the user's ahoy file, skipping any
+// that the project already defines. Files are loaded relative to the user's
+// config directory, but commands run from the project (or current) directory.
+func getUserCommands(file string, config Config, projectCommands []cli.Command) ([]cli.Command, error) {
+	userCommands := []cli.Command{}
+	if file == "" {
+		return userCommands, nil
+	}
+
+	srcDir := AhoyConf.srcDir
+	defer func() {
+		AhoyConf.srcDir = srcDir
+		AhoyConf.runDir = ""
+	}()
+	AhoyConf.runDir = srcDir
+	if AhoyConf.srcFile == "" {
+		if cwd, err := os.Getwd(); err == nil {
+			AhoyConf.runDir = cwd
+		}
+	}
+	AhoyConf.srcDir = filepath.Dir(file)
+
+	projectNames := map[string]bool{}
+	for _, command := range projectCommands {
+		projectNames[command.Name] = true
+	}
+	commands, sources, err := loadCommands(config, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, command := range commands {
+		if projectNames[command.Name] {
+			if project := commandSources[command.Name]; project != nil {
+				project.override(sources[command.Name])
+			}
+			continue
+		}
+		commandSources[command.Name] = sources[command.Name]
+		command.Category = userCommandsCategory
+		userCommands = append(userCommands, command)
+	}
+	return userCommands, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+func TestUserCommands(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+
+	// Without a user file there are no user commands.
+	file, config, err := getUserConfig()
+	if err != nil || file != "" {
+		t.Fatalf("Expected no user config, got %s: %v", file, err)
+	}
+	if commands, _ := getUserCommands(file, config, nil); len(commands) != 0 {
+		t.Errorf("Expected no user commands, got %d", len(commands))
+	}
+
+	os.MkdirAll(filepath.Join(configDir, "ahoy"), 0o755)
+	os.WriteFile(filepath.Join(configDir, "ahoy", "ahoy.yml"), []byte("ahoyapi: v2\ncommands:\n  myip:\n    cmd: curl ifconfig.me\n  build:\n    cmd: echo mine\n"), 0o644)
+	file, config, err = getUserConfig()
+	if err != nil || file == "" {
+		t.Fatalf("Expected the user config to be loaded, got: %v", err)
+	}
+
+	AhoyConf.srcDir = "testdata"
+	projectCommands := []cli.Command{{Name: "build"}}
+	commands, _ := getUserCommands(file, config, projectCommands)
+	if len(commands) != 1 || commands[0].Name != "myip" {
+		t.Fatalf("Expected project commands to take precedence, got: %v", commands)
+	}
+	if commands[0].Category != userCommandsCategory {
+		t.Errorf("Expected user commands to be grouped separately, got %q", commands[0].Category)
+	}
+	if AhoyConf.srcDir != "testdata" || AhoyConf.runDir != "" {
+		t.Errorf("Expected the directories to be restored, got %q and %q", AhoyConf.srcDir, AhoyConf.runDir)
+	}
+	AhoyConf.srcDir = ""
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultMigrateAPI is the API version 'ahoy migrate' upgrades files to,
+// unless --to is given. Files are kept at v2, as ahoy releases from before v3
+// was added refuse to load v3 files.
+const defaultMigrateAPI = "v2"
+
+// ahoyAPIVersion describes how to load an ahoy file written for an API version.
+type ahoyAPIVersion struct {
+	// normalize converts the raw file to the v2 layout the Config struct
+	// uses, or is nil if nothing needs to change.
+	normalize func(raw map[any]any) error
+	// strict rejects fields that aren't known.
+	strict bool
+	// migrate rewrites the text of a file to the next API version, keeping
+	// comments and formatting intact.
+	migrate func(text string) string
+	next    string
+}
+
+var ahoyAPIVersions = map[string]ahoyAPIVersion{
+	"v1": {normalize: normalizeV1, migrate: migrateV1, next: "v2"},
+	"v2": {migrate: migrateV2, next: "v3"},
+	"v3": {normalize: normalizeV3, strict: true},
+}
+
+// supportedAhoyAPIs lists the versions in ahoyAPIVersions for error messages.
+const supportedAhoyAPIs = "'v1', 'v2' and 'v3'"
+
+// unmarshalVersionedConfig parses YAML written for any supported API version
+// into the config.
+func unmarshalVersionedConfig(file string, data []byte, config *Config) error {
+	raw := map[any]any{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	// Check the required version first, so files using features added after
+	// this version of ahoy explain what's wrong instead of failing to parse.
+	requires, _ := raw["requires_ahoy"].(string)
+	if err := checkRequiredAhoy(file, requires); err != nil {
+		return err
+	}
+	api, _ := raw["ahoyapi"].(string)
+	version, supported := ahoyAPIVersions[api]
+	if !supported {
+		return errors.New("Ahoy only supports API versions " + supportedAhoyAPIs + ", but '" + api + "' given in " + file)
+	}
+
+	if version.normalize != nil {
+		if err := version.normalize(raw); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		normalized, err := yaml.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		data = normalized
+	}
+	if version.strict {
+		if err := yaml.UnmarshalStrict(data, config); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	} else if err := yaml.Unmarshal(data, config); err != nil {
+		return err
+	}
+	return nil
+}
+
+// rawCommands returns the commands of a raw ahoy file.
+func rawCommands(raw map[any]any) map[any]any {
+	commands, _ := raw["commands"].(map[any]any)
+	return commands
+}
+
+// normalizeV1 converts v1 files, where {{args}} was replaced by the arguments
+// and a single subcommand file could be set using 'import'.
+func normalizeV1(raw map[any]any) error {
+	for name, value := range rawCommands(raw) {
+		command, ok := value.(map[any]any)
+		if !ok {
+			continue
+		}
+		if cmd, ok := command["cmd"].(string); ok {
+			command["cmd"] = strings.ReplaceAll(cmd, "{{args}}", "$@")
+		}
+		if include, ok := command["import"]; ok {
+			if _, exists := command["imports"]; exists {
+				return fmt.Errorf("command [%v] has both 'import' and 'imports' set", name)
+			}
+			command["imports"] = []any{include}
+			delete(command, "import")
+		}
+	}
+	return nil
+}
+
+// normalizeV3 converts v3 files, where 'cmd' can also be a list of lines.
+func normalizeV3(raw map[any]any) error {
+	for name, value := range rawCommands(raw) {
+		command, ok := value.(map[any]any)
+		if !ok {
+			continue
+		}
+		lines, ok := command["cmd"].([]any)
+		if !ok {
+			continue
+		}
+		cmd := []string{}
+		for _, line := range lines {
+			text, ok := line.(string)
+			if !ok {
+				return fmt.Errorf("command [%v] has a 'cmd' list with a line that isn't a string", name)
+			}
+			cmd = append(cmd, text)
+		}
+		command["cmd"] = strings.Join(cmd, "\n")
+	}
+	return nil
+}
+
+var (
+	ahoyAPILine = regexp.MustCompile(`(?m)^(ahoyapi:\s*)(["']?)v[0-9]+(["']?)`)
+	importLine  = regexp.MustCompile(`(?m)^(\s+)import:[ \t]*([^#\s][^#\n]*?)([ \t]*#.*)?$`)
+)
+
+// setAhoyAPI rewrites the ahoyapi line of a file.
+func setAhoyAPI(text string, api string) string {
+	return ahoyAPILine.ReplaceAllString(text, "${1}${2}"+api+"${3}")
+}
+
+func migrateV1(text string) string {
+	text = strings.ReplaceAll(text, "{{args}}", "$@")
+	text = importLine.ReplaceAllString(text, "${1}imports: [${2}]${3}")
+	return setAhoyAPI(text, "v2")
+}
+
+// migrateV2 only changes the version, as v3 files are a stricter superset of v2.
+func migrateV2(text string) string {
+	return setAhoyAPI(text, "v3")
+}
+
+// migrateConfig rewrites the text of an ahoy file to the target API version.
+// Files already at or past the target are left as they are.
+func migrateConfig(file string, text string, target string) (string, error) {
+	if _, supported := ahoyAPIVersions[target]; !supported {
+		return "", errors.New("Ahoy can only migrate files to API versions " + supportedAhoyAPIs + ", but '" + target + "' given.")
+	}
+	raw := map[any]any{}
+	if err := yaml.Unmarshal([]byte(text), &raw); err != nil {
+		return "", err
+	}
+	api, _ := raw["ahoyapi"].(string)
+	version, supported := ahoyAPIVersions[api]
+	if !supported {
+		return "", errors.New("Ahoy only supports API versions " + supportedAhoyAPIs + ", but '" + api + "' given in " + file)
+	}
+	for api != target && version.migrate != nil {
+		text = version.migrate(text)
+		api, version = version.next, ahoyAPIVersions[version.next]
+	}
+
+	// Make sure the migrated file loads, as fields unknown to the latest
+	// version can't be migrated automatically.
+	config := Config{}
+	if err := unmarshalVersionedConfig(file, []byte(text), &config); err != nil {
+		return "", errors.New("The migrated file doesn't load, so it needs to be fixed by hand: " + err.Error())
+	}
+	return text, nil
+}
+
+func migrateAction(c *cli.Context) error {
+	file := AhoyConf.srcFile
+	if len(c.Args()) > 0 {
+		file = c.Args()[0]
+	}
+	if file == "" || file == stdinConfigFile {
+		return errors.New("Please specify the ahoy file to migrate, e.g. 'ahoy migrate .ahoy.yml'.")
+	}
+	if ext := strings.ToLower(file); strings.HasSuffix(ext, ".json") || strings.HasSuffix(ext, ".toml") {
+		return errors.New("Only YAML ahoy files can be migrated, but " + file + " was given.")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	target := c.String("to")
+	migrated, err := migrateConfig(file, string(data), target)
+	if err != nil {
+		return configError(err)
+	}
+	if migrated == string(data) {
+		fmt.Println(file + " already uses ahoyapi " + target + " or later.")
+		return nil
+	}
+
+	fmt.Print(unifiedDiff(file, string(data), migrated))
+	if c.Bool("dry-run") || dryRun {
+		return nil
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, []byte(migrated), info.Mode().Perm()); err != nil {
+		return err
+	}
+	fmt.Println("Migrated " + file + " to ahoyapi " + target + ".")
+	return nil
+}
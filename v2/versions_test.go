@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadV1Config(t *testing.T) {
+	config, err := getConfig("testdata/v1.ahoy.yml")
+	if err != nil {
+		t.Fatalf("Failed to load v1 file: %v", err)
+	}
+	if config.Commands["hello"].Cmd != "echo hello $@" {
+		t.Errorf("Expected {{args}} to be replaced, got %q", config.Commands["hello"].Cmd)
+	}
+	if !reflect.DeepEqual(config.Commands["tools"].Imports, []string{"simple.ahoy.yml"}) {
+		t.Errorf("Expected 'import' to become 'imports', got %v", config.Commands["tools"].Imports)
+	}
+}
+
+func TestLoadV3Config(t *testing.T) {
+	config, err := getConfig("testdata/v3.ahoy.yml")
+	if err != nil {
+		t.Fatalf("Failed to load v3 file: %v", err)
+	}
+	if config.Commands["hello"].Cmd != "greeting=\"hello\"\necho \"$greeting $@\"" {
+		t.Errorf("Expected the cmd lines to be joined, got %q", config.Commands["hello"].Cmd)
+	}
+
+	// v3 files are strict, so typos are caught.
+	config = Config{}
+	err = unmarshalVersionedConfig("typo.ahoy.yml", []byte("ahoyapi: v3\ncommands:\n  hello:\n    cmd: echo\n    usgae: typo\n"), &config)
+	if err == nil || !strings.Contains(err.Error(), "usgae") {
+		t.Errorf("Expected an unknown field to be rejected, got: %v", err)
+	}
+}
+
+func TestUnsupportedAPIVersion(t *testing.T) {
+	for _, data := range []string{"ahoyapi: v9\n", "commands: {}\n"} {
+		config := Config{}
+		err := unmarshalVersionedConfig("bogus.ahoy.yml", []byte(data), &config)
+		if err == nil || !strings.Contains(err.Error(), "API versions") {
+			t.Errorf("Expected %q to be rejected, got: %v", data, err)
+		}
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	data, err := os.ReadFile("testdata/v1.ahoy.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := migrateConfig("testdata/v1.ahoy.yml", string(data), defaultMigrateAPI)
+	if err != nil {
+		t.Fatalf("Unexpected error migrating: %v", err)
+	}
+	expected := strings.Join([]string{
+		"# An ahoy file written for the v1 API.",
+		"ahoyapi: v2",
+		"usage: Legacy commands",
+		"commands:",
+		"  hello:",
+		"    usage: Say hello",
+		"    cmd: echo hello $@",
+		"  tools:",
+		"    usage: Tools from another file",
+		"    imports: [simple.ahoy.yml] # Imported commands",
+		"",
+	}, "\n")
+	if migrated != expected {
+		t.Errorf("Unexpected migration:\n%s\nexpected:\n%s", migrated, expected)
+	}
+
+	// v2 files are kept at v2 unless v3 is asked for, so older releases of
+	// ahoy can still load them.
+	again, err := migrateConfig("testdata/v1.ahoy.yml", migrated, defaultMigrateAPI)
+	if err != nil || again != migrated {
+		t.Errorf("Expected migrating a v2 file to change nothing, got: %v", err)
+	}
+	v3, err := migrateConfig("testdata/v1.ahoy.yml", migrated, "v3")
+	if err != nil || v3 != strings.Replace(migrated, "ahoyapi: v2", "ahoyapi: v3", 1) {
+		t.Errorf("Expected the file to be migrated to v3, got %v:\n%s", err, v3)
+	}
+	if again, err := migrateConfig("testdata/v1.ahoy.yml", v3, defaultMigrateAPI); err != nil || again != v3 {
+		t.Errorf("Expected migrating a v3 file to v2 to change nothing, got: %v", err)
+	}
+	if _, err := migrateConfig("testdata/v1.ahoy.yml", migrated, "v4"); err == nil {
+		t.Error("Expected an unknown target version to fail migration.")
+	}
+
+	// Files with unknown fields can't be migrated to v3 automatically.
+	if _, err := migrateConfig("typo.ahoy.yml", "ahoyapi: v2\ncommands:\n  hello:\n    cmd: echo\n    usgae: typo\n", "v3"); err == nil {
+		t.Error("Expected a file with an unknown field to fail migration.")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	if diff := unifiedDiff("same.yml", "a\nb\n", "a\nb\n"); diff != "" {
+		t.Errorf("Expected no diff for identical files, got %q", diff)
+	}
+
+	before := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	after := "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\nFIFTEEN\n"
+	expected := `--- numbers
++++ numbers
+@@ -1,5 +1,5 @@
+ 1
+-2
++TWO
+ 3
+ 4
+ 5
+@@ -12,5 +12,5 @@
+ 12
+ 13
+ 14
+-15
++FIFTEEN
+ 
+`
+	if diff := unifiedDiff("numbers", before, after); diff != expected {
+		t.Errorf("Unexpected diff:\n%s\nexpected:\n%s", diff, expected)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// Files are polled rather than watched using OS events, so watching works the
+// same everywhere, including in containers and on network file systems.
+const (
+	defaultWatchInterval = 500 * time.Millisecond
+	defaultWatchDebounce = 200 * time.Millisecond
+)
+
+// watcher holds the options for 'ahoy watch'.
+type watcher struct {
+	// interval is how often files are checked for changes.
+	interval time.Duration
+	// debounce is how long files must stay unchanged before the command is
+	// run again, so saving several files at once only runs it once.
+	debounce time.Duration
+}
+
+// pendingWatch is set by 'ahoy watch' while it starts the command to watch.
+var pendingWatch *watcher
+
+// claimWatch returns the watcher when the command is being started by 'ahoy
+// watch', so it runs the command each time its files change.
+func claimWatch() *watcher {
+	w := pendingWatch
+	pendingWatch = nil
+	return w
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchSnapshot records the state of the files in dir matching the globs.
+func watchSnapshot(dir string, globs []string) (map[string]fileState, error) {
+	files, err := globFiles(dir, globs)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := map[string]fileState{}
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			// The file was removed since the globs were matched.
+			continue
+		}
+		snapshot[file] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return snapshot, nil
+}
+
+// changedFiles returns the files added, removed or modified between two
+// snapshots.
+func changedFiles(before map[string]fileState, after map[string]fileState) []string {
+	changed := []string{}
+	for file, state := range after {
+		if previous, found := before[file]; !found || previous != state {
+			changed = append(changed, file)
+		}
+	}
+	for file := range before {
+		if _, found := after[file]; !found {
+			changed = append(changed, file)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// run runs a command, and runs it again each time the files in dir matching
+// the globs change. A run that is still going is stopped first, in the same
+// way as when ahoy receives SIGTERM. Watching stops when ahoy is interrupted,
+// or when the command is interrupted using Ctrl+C.
+func (w *watcher) run(name string, dir string, globs []string, run func() error) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+	defer func() { restartCommand = nil }()
+
+	snapshot, err := watchSnapshot(dir, globs)
+	if err != nil {
+		return errors.New("Could not watch the files of '" + name + "': " + err.Error())
+	}
+	logger("info", "Watching "+strconv.Itoa(len(snapshot))+" files for '"+name+"'. Press Ctrl+C to stop.")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		// Each run gets its own channel, so a restart can't reach the next run.
+		restart := make(chan os.Signal, 1)
+		restartCommand = restart
+		done := make(chan error, 1)
+		go func() {
+			done <- run()
+		}()
+
+		running, restarting := true, false
+		var settled <-chan time.Time
+		changed := []string{}
+		for running || !restarting {
+			select {
+			case err := <-done:
+				running = false
+				if restarting {
+					continue
+				}
+				code, show := exitCode(err)
+				if code == 128+int(syscall.SIGINT) {
+					return err
+				}
+				if show {
+					logger("error", err.Error())
+				}
+				if err != nil {
+					logger("warn", "'"+name+"' failed with exit code "+strconv.Itoa(code)+". Waiting for changes.")
+				} else {
+					logger("info", "'"+name+"' finished. Waiting for changes.")
+				}
+			case sig := <-signals:
+				// The running command is passed the signal by runCommand.
+				if running {
+					<-done
+				}
+				return &exitStatus{code: 128 + int(sig.(syscall.Signal))}
+			case <-ticker.C:
+				if restarting {
+					continue
+				}
+				next, err := watchSnapshot(dir, globs)
+				if err != nil {
+					logger("warn", "Could not check the files of '"+name+"' for changes: "+err.Error())
+					continue
+				}
+				if files := changedFiles(snapshot, next); len(files) > 0 {
+					snapshot = next
+					changed = append(changed, files...)
+					settled = time.After(w.debounce)
+				}
+			case <-settled:
+				settled = nil
+				what := changed[0] + " changed"
+				if len(changed) > 1 {
+					what = strconv.Itoa(len(changed)) + " files changed"
+				}
+				changed = []string{}
+				logger("info", what+", running '"+name+"' again.")
+				restarting = true
+				if running {
+					restart <- syscall.SIGTERM
+				}
+			}
+		}
+	}
+}
+
+// findCommand returns the command named by the start of args, following
+// commands with subcommands, and the arguments left for it.
+func findCommand(commands []cli.Command, args []string) (*cli.Command, []string) {
+	var found *cli.Command
+	for len(args) > 0 {
+		var next *cli.Command
+		for i := range commands {
+			if commands[i].HasName(args[0]) {
+				next = &commands[i]
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		found, args = next, args[1:]
+		if len(found.Subcommands) == 0 {
+			break
+		}
+		commands = found.Subcommands
+	}
+	return found, args
+}
+
+func watchAction(c *cli.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return errors.New("Usage: ahoy watch <command> [args...]")
+	}
+	command, commandArgs := findCommand(c.App.Commands, args)
+	if command == nil {
+		return newAhoyError(exitCommandNotFound, errors.New("Command not found for '"+args[0]+"'"))
+	}
+	// Only commands from ahoy files skip flag parsing, passing all their
+	// arguments on to 'cmd'.
+	if command.Action == nil || len(command.Subcommands) > 0 || !command.SkipFlagParsing {
+		return errors.New("'" + command.Name + "' can't be watched, only commands with a 'cmd' in an ahoy file can.")
+	}
+
+	if c.Duration("interval") <= 0 || c.Duration("debounce") < 0 {
+		return errors.New("The --interval and --debounce of 'ahoy watch' must be positive durations, such as '1s'.")
+	}
+
+	pendingWatch = &watcher{interval: c.Duration("interval"), debounce: c.Duration("debounce")}
+	defer func() { pendingWatch = nil }()
+	return invokeCommand(c, command, commandArgs)
+}
+
+// invokeCommand runs a command found using findCommand from another command,
+// passing it all the arguments as they were given.
+func invokeCommand(c *cli.Context, command *cli.Command, args []string) error {
+	set := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	set.Parse(append([]string{"--"}, args...))
+	context := cli.NewContext(c.App, set, c)
+	context.Command = *command
+	return cli.HandleAction(command.Action, context)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+func TestChangedFiles(t *testing.T) {
+	now := time.Now()
+	before := map[string]fileState{
+		"same.txt":    {modTime: now, size: 1},
+		"changed.txt": {modTime: now, size: 1},
+		"removed.txt": {modTime: now, size: 1},
+	}
+	after := map[string]fileState{
+		"same.txt":    {modTime: now, size: 1},
+		"changed.txt": {modTime: now.Add(time.Second), size: 1},
+		"added.txt":   {modTime: now, size: 1},
+	}
+	expected := []string{"added.txt", "changed.txt", "removed.txt"}
+	if changed := changedFiles(before, after); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected %v, got %v", expected, changed)
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	commands := []cli.Command{
+		{Name: "build", Aliases: []string{"b"}},
+		{Name: "docker", Subcommands: []cli.Command{{Name: "up"}}},
+	}
+	if command, args := findCommand(commands, []string{"b", "--prod"}); command == nil || command.Name != "build" || !reflect.DeepEqual(args, []string{"--prod"}) {
+		t.Errorf("Expected to find build by its alias, got %v %v", command, args)
+	}
+	if command, args := findCommand(commands, []string{"docker", "up", "-d"}); command == nil || command.Name != "up" || !reflect.DeepEqual(args, []string{"-d"}) {
+		t.Errorf("Expected to find the subcommand, got %v %v", command, args)
+	}
+	if command, _ := findCommand(commands, []string{"test"}); command != nil {
+		t.Errorf("Expected no command to be found, got %v", command)
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "input.txt")
+	os.WriteFile(file, []byte("one"), 0644)
+
+	runs := 0
+	run := func() error {
+		runs++
+		if runs == 1 {
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				os.WriteFile(file, []byte("changed"), 0644)
+			}()
+			return nil
+		}
+		// Stop watching, as if Ctrl+C was pressed.
+		return &exitStatus{code: 130}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		w := &watcher{interval: 20 * time.Millisecond, debounce: 20 * time.Millisecond}
+		done <- w.run("test", dir, []string{"*.txt"}, run)
+	}()
+	select {
+	case err := <-done:
+		if code, _ := exitCode(err); code != 130 {
+			t.Errorf("Expected watching to stop when the command is interrupted, got %d: %v", code, err)
+		}
+		if runs != 2 {
+			t.Errorf("Expected the command to run again when its file changed, got %d runs", runs)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the command to run again when its file changed")
+	}
+}
@@ -159,7 +159,7 @@ func TestWindowsCommandExecution(t *testing.T) {
 		Entrypoint: []string{"cmd", "/c", "{{cmd}}"},
 	}
 
-	commands := getCommands(config)
+	commands, _ := getCommands(config)
 	if len(commands) == 0 {
 		t.Error("No commands generated from config")
 	}